@@ -0,0 +1,142 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// maxAMSUnits is how many AMS units a printer can chain, each with four
+// trays. The *_bits fields of the report hold one bit per unit or tray.
+const maxAMSUnits = 4
+
+// amsBits decodes one of the hex bitfields of the AMS report.
+func amsBits(s string) (uint64, bool) {
+	if s == "" {
+		return 0, false
+	}
+	bits, err := strconv.ParseUint(s, 16, 64)
+	return bits, err == nil
+}
+
+func bitValue(bits uint64, n int) float64 {
+	return float64(bits >> uint(n) & 1)
+}
+
+// trayLoaded reports whether a spool is in the tray. Reports without
+// tray_exist_bits fall back to whether the tray has a filament type.
+func trayLoaded(report BambuLabsX1C, amsID, trayID int, trayType string) bool {
+	if bits, ok := amsBits(report.Print.Ams.TrayExistBits); ok {
+		return bitValue(bits, amsID*4+trayID) == 1
+	}
+	return trayType != ""
+}
+
+// collectAMSBits exports which AMS units are connected and the state of
+// each of their trays.
+func (collector *bambulabsCollector) collectAMSBits(ch chan<- prometheus.Metric, labels []string, report BambuLabsX1C) {
+	ams := report.Print.Ams
+
+	present, ok := amsBits(ams.AmsExistBits)
+	if !ok {
+		return
+	}
+	for unit := 0; unit < maxAMSUnits; unit++ {
+		ch <- prometheus.MustNewConstMetric(collector.amsPresentMetric, prometheus.GaugeValue, bitValue(present, unit), append(labels[:2:2], strconv.Itoa(unit))...)
+	}
+
+	// Only report trays of AMS units that are connected.
+	trayBits := []struct {
+		desc *prometheus.Desc
+		bits string
+	}{
+		{collector.amsTrayLoadedMetric, ams.TrayExistBits},
+		{collector.amsTrayIsBambuMetric, ams.TrayIsBblBits},
+		{collector.amsTrayRFIDReadingMetric, ams.TrayReadingBits},
+		{collector.amsTrayRFIDReadDoneMetric, ams.TrayReadDoneBits},
+	}
+	for _, field := range trayBits {
+		bits, ok := amsBits(field.bits)
+		if !ok {
+			continue
+		}
+		for unit := 0; unit < maxAMSUnits; unit++ {
+			if bitValue(present, unit) == 0 {
+				continue
+			}
+			for tray := 0; tray < 4; tray++ {
+				trayLabels := append(labels[:2:2], strconv.Itoa(unit), strconv.Itoa(tray))
+				ch <- prometheus.MustNewConstMetric(field.desc, prometheus.GaugeValue, bitValue(bits, unit*4+tray), trayLabels...)
+			}
+		}
+	}
+}
+
+// collectAMS exports the AMS units and the spool in each of their trays.
+func (collector *bambulabsCollector) collectAMS(ch chan<- prometheus.Metric, labels []string, report BambuLabsX1C) {
+	//Loop through the AMS
+	for x, ams := range report.Print.Ams.Ams {
+		// Label by the unit and tray IDs rather than list positions, so the
+		// series match the *_bits and tray_now metrics when a unit is
+		// missing from the list.
+		amsID, err := strconv.Atoi(ams.ID)
+		if err != nil {
+			amsID = x
+		}
+		amsLabels := append(labels[:2:2], strconv.Itoa(amsID))
+
+		ams_temp, _ := strconv.ParseFloat(ams.Temp, 64)
+		ch <- prometheus.MustNewConstMetric(collector.amsTempMetric, prometheus.GaugeValue, ams_temp, amsLabels...)
+
+		humidity, _ := strconv.ParseFloat(ams.Humidity, 64)
+		ch <- prometheus.MustNewConstMetric(collector.amsHumidityMetric, prometheus.GaugeValue, humidity, amsLabels...)
+
+		// loop through the Trays
+		for i, tray := range ams.Tray {
+			trayID, err := strconv.Atoi(tray.ID)
+			if err != nil {
+				trayID = i
+			}
+			// Empty slots are still listed, skip them rather than report a
+			// tray without a color or type.
+			if !trayLoaded(report, amsID, trayID, tray.TrayType) {
+				continue
+			}
+
+			trayLabels := append(amsLabels[:3:3], strconv.Itoa(trayID))
+
+			ams_bed_temp, _ := strconv.ParseFloat(tray.BedTemp, 64)
+			ch <- prometheus.MustNewConstMetric(collector.amsBedTempMetric, prometheus.GaugeValue, ams_bed_temp, trayLabels...)
+
+			ch <- prometheus.MustNewConstMetric(collector.amsColorMetric, prometheus.GaugeValue, 1, append(trayLabels[:4:4], tray.TrayColor, tray.TrayType)...)
+
+			// Trays without a spool, or with one the AMS could not read, leave
+			// these empty.
+			trayGauge := func(desc *prometheus.Desc, value string) {
+				if value != "" {
+					ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, parseFloat(value), trayLabels...)
+				}
+			}
+			trayGauge(collector.amsTrayWeightMetric, tray.TrayWeight)
+			trayGauge(collector.amsTrayDiameterMetric, tray.TrayDiameter)
+			trayGauge(collector.amsTrayNozzleTempMinMetric, tray.NozzleTempMin)
+			trayGauge(collector.amsTrayNozzleTempMaxMetric, tray.NozzleTempMax)
+			trayGauge(collector.amsTrayDryingTempMetric, tray.DryingTemp)
+			// drying_time is in hours.
+			if tray.DryingTime != "" {
+				ch <- prometheus.MustNewConstMetric(collector.amsTrayDryingTimeMetric, prometheus.GaugeValue, parseFloat(tray.DryingTime)*3600, trayLabels...)
+			}
+
+			// remain is -1 when the AMS doesn't know how much is left, such as
+			// for spools without an RFID tag.
+			if tray.TrayType != "" && tray.Remain >= 0 {
+				ch <- prometheus.MustNewConstMetric(collector.amsTrayRemainMetric, prometheus.GaugeValue, float64(tray.Remain), trayLabels...)
+			}
+
+			if tray.TrayType != "" {
+				ch <- prometheus.MustNewConstMetric(collector.amsTrayInfoMetric, prometheus.GaugeValue, 1,
+					append(trayLabels[:4:4], tray.TraySubBrands, tray.TrayIDName, tray.TrayInfoIdx, tray.TagUID, tray.TrayUUID)...)
+			}
+		}
+	}
+}
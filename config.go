@@ -0,0 +1,217 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// duration is a time.Duration that reads as a string such as "5m" in JSON.
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(parsed)
+	return nil
+}
+
+// printerConfig describes a single printer the exporter connects to.
+type printerConfig struct {
+	Name       string `json:"name"`
+	Address    string `json:"address"`
+	Port       int    `json:"port"`
+	Username   string `json:"username"`
+	AccessCode string `json:"access_code"`
+	Serial     string `json:"serial"`
+
+	// ClientID overrides the generated MQTT client ID.
+	ClientID string `json:"client_id"`
+
+	// TLSVerify checks the printer certificate against CAFile, which is
+	// required with it, and the configured serial.
+	TLSVerify   bool   `json:"tls_verify"`
+	CAFile      string `json:"ca_file"`
+	Fingerprint string `json:"fingerprint"`
+
+	// PushallInterval overrides the exporter wide pushall_interval.
+	PushallInterval *duration `json:"pushall_interval"`
+}
+
+type exporterConfig struct {
+	// PushallInterval is how often a full report is requested from each
+	// printer. Zero only requests one when connecting.
+	PushallInterval duration `json:"pushall_interval"`
+
+	// MaxReportAge is how long after its last message a printer is treated
+	// as offline. Its metrics are then dropped unless KeepStaleSeries is set.
+	MaxReportAge    duration `json:"max_report_age"`
+	KeepStaleSeries bool     `json:"keep_stale_series"`
+
+	Printers []printerConfig `json:"printers"`
+}
+
+const (
+	defaultPushallInterval = 5 * time.Minute
+	defaultMaxReportAge    = 10 * time.Minute
+)
+
+// loadConfig reads the printer list from the JSON file named by CONFIG_FILE.
+// Without one it falls back to the single printer described by the
+// BAMBU_PRINTER_IP, USERNAME, PASSWORD and MQTT_TOPIC variables.
+func loadConfig() (exporterConfig, error) {
+	config := exporterConfig{
+		PushallInterval: duration(defaultPushallInterval),
+		MaxReportAge:    duration(defaultMaxReportAge),
+	}
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return config, fmt.Errorf("reading %s: %w", path, err)
+		}
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return config, fmt.Errorf("parsing %s: %w", path, err)
+		}
+	} else {
+		printer, err := envPrinterConfig()
+		if err != nil {
+			return config, err
+		}
+		config.Printers = []printerConfig{printer}
+	}
+
+	if interval := os.Getenv("PUSHALL_INTERVAL"); interval != "" {
+		parsed, err := time.ParseDuration(interval)
+		if err != nil {
+			return config, fmt.Errorf("PUSHALL_INTERVAL: %w", err)
+		}
+		config.PushallInterval = duration(parsed)
+	}
+
+	if age := os.Getenv("MAX_REPORT_AGE"); age != "" {
+		parsed, err := time.ParseDuration(age)
+		if err != nil {
+			return config, fmt.Errorf("MAX_REPORT_AGE: %w", err)
+		}
+		config.MaxReportAge = duration(parsed)
+	}
+
+	if keep := os.Getenv("KEEP_STALE_SERIES"); keep != "" {
+		parsed, err := strconv.ParseBool(keep)
+		if err != nil {
+			return config, fmt.Errorf("KEEP_STALE_SERIES: %w", err)
+		}
+		config.KeepStaleSeries = parsed
+	}
+
+	if len(config.Printers) == 0 {
+		return config, fmt.Errorf("no printers configured")
+	}
+	if config.MaxReportAge <= 0 {
+		return config, fmt.Errorf("max_report_age must be greater than 0")
+	}
+
+	seen := map[string]bool{}
+	for i := range config.Printers {
+		p := &config.Printers[i]
+		if p.Address == "" || p.Serial == "" {
+			return config, fmt.Errorf("printer %d: address and serial are required", i)
+		}
+		if p.Port == 0 {
+			p.Port = 8883
+		}
+		if p.Username == "" {
+			p.Username = "bblp"
+		}
+		if p.Name == "" {
+			p.Name = p.Serial
+		}
+		if p.ClientID == "" {
+			p.ClientID = newClientID(p.Serial)
+		}
+		if p.TLSVerify && p.CAFile == "" {
+			return config, fmt.Errorf("printer %s: tls_verify needs a ca_file", p.Name)
+		}
+		if p.PushallInterval == nil {
+			p.PushallInterval = &config.PushallInterval
+		}
+		// Idle printers may only report when asked, so they would look
+		// offline between two pushall requests.
+		if *p.PushallInterval < 0 || *p.PushallInterval >= config.MaxReportAge {
+			return config, fmt.Errorf("printer %s: pushall_interval %s must be at least 0 and below max_report_age %s", p.Name, time.Duration(*p.PushallInterval), time.Duration(config.MaxReportAge))
+		}
+		if seen[p.Serial] {
+			return config, fmt.Errorf("printer %s configured more than once", p.Serial)
+		}
+		seen[p.Serial] = true
+	}
+
+	return config, nil
+}
+
+func envPrinterConfig() (printerConfig, error) {
+	broker := env("BAMBU_PRINTER_IP")
+	username := env("USERNAME")
+	password := env("PASSWORD")
+	mqtt_topic := env("MQTT_TOPIC")
+
+	if broker == "" {
+		broker = os.Getenv("BAMBU_PRINTER_IP")
+	}
+
+	if password == "" {
+		password = os.Getenv("PASSWORD")
+	}
+
+	if mqtt_topic == "device/<>/report" {
+		mqtt_topic = os.Getenv("MQTT_TOPIC")
+	}
+
+	tls_verify := false
+	if verify := env("TLS_VERIFY"); verify != "" {
+		parsed, err := strconv.ParseBool(verify)
+		if err != nil {
+			return printerConfig{}, fmt.Errorf("TLS_VERIFY: %w", err)
+		}
+		tls_verify = parsed
+	}
+
+	// MQTT_TOPIC looks like device/<serial>/report
+	serial := strings.TrimSuffix(strings.TrimPrefix(mqtt_topic, "device/"), "/report")
+
+	return printerConfig{
+		Address:    broker,
+		Username:   username,
+		AccessCode: password,
+		Serial:     serial,
+		ClientID:   env("CLIENT_ID"),
+
+		TLSVerify:   tls_verify,
+		CAFile:      env("CA_FILE"),
+		Fingerprint: env("TLS_FINGERPRINT"),
+	}, nil
+}
+
+// newClientID builds a client ID that is unique per exporter process, so
+// replicas and other tools don't kick each other off the printer's broker.
+func newClientID(serial string) string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "unknown"
+	}
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+	return fmt.Sprintf("bambulabs-exporter-%s-%s-%s", hostname, serial, hex.EncodeToString(suffix))
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// fanMaxLevel is the level of a fan at full speed in the *_fan_speed
+	// fields.
+	fanMaxLevel = 15
+
+	// fanGearMax is the full speed value of a fan in fan_gear.
+	fanGearMax = 255
+)
+
+// fan is one of the fans the printer reports. gearShift is the position of
+// its byte in fan_gear, or -1 when fan_gear doesn't carry it.
+type fan struct {
+	name      string
+	level     func(report BambuLabsX1C) string
+	gearShift int
+}
+
+var fans = []fan{
+	{"part", func(report BambuLabsX1C) string { return report.Print.CoolingFanSpeed }, 0},
+	{"aux", func(report BambuLabsX1C) string { return report.Print.BigFan1Speed }, 8},
+	{"chamber", func(report BambuLabsX1C) string { return report.Print.BigFan2Speed }, 16},
+	{"heatbreak", func(report BambuLabsX1C) string { return report.Print.HeatbreakFanSpeed }, -1},
+}
+
+// fanGearValue returns the byte of a fan in fan_gear, which packs the part,
+// aux and chamber fans from the lowest byte up.
+func fanGearValue(gear, shift int) int {
+	return (gear >> shift) & 0xff
+}
+
+// collectFans exports the speed of each fan, both as the printer reports it
+// and in percent. Fans without a level fall back to their fan_gear value, as
+// some firmware only sends that.
+func (collector *bambulabsCollector) collectFans(ch chan<- prometheus.Metric, labels []string, report BambuLabsX1C) {
+	gear := report.Print.FanGear
+	for _, f := range fans {
+		fanLabels := append(labels[:2:2], f.name)
+
+		if f.gearShift >= 0 {
+			ch <- prometheus.MustNewConstMetric(collector.fanGearMetric, prometheus.GaugeValue, float64(fanGearValue(gear, f.gearShift)), fanLabels...)
+		}
+
+		level, err := strconv.Atoi(f.level(report))
+		switch {
+		case err == nil:
+			ch <- prometheus.MustNewConstMetric(collector.fanLevelMetric, prometheus.GaugeValue, float64(level), fanLabels...)
+			ch <- prometheus.MustNewConstMetric(collector.fanPercentMetric, prometheus.GaugeValue, float64(level)/fanMaxLevel*100, fanLabels...)
+		case f.gearShift >= 0:
+			percent := float64(fanGearValue(gear, f.gearShift)) / fanGearMax * 100
+			ch <- prometheus.MustNewConstMetric(collector.fanPercentMetric, prometheus.GaugeValue, percent, fanLabels...)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// moduleVersion is one entry of the get_version response, such as the
+// main board ("ota"), the motion controller ("mc") or an AMS ("ams/0").
+type moduleVersion struct {
+	Name  string `json:"name"`
+	SwVer string `json:"sw_ver"`
+	HwVer string `json:"hw_ver"`
+	SN    string `json:"sn"`
+}
+
+// decodeVersion returns the modules of a get_version response, or false
+// for any other message.
+func decodeVersion(payload []byte) ([]moduleVersion, bool) {
+	var msg struct {
+		Info *struct {
+			Command string          `json:"command"`
+			Module  []moduleVersion `json:"module"`
+		} `json:"info"`
+	}
+	if err := json.Unmarshal(payload, &msg); err != nil || msg.Info == nil || msg.Info.Command != "get_version" {
+		return nil, false
+	}
+	return msg.Info.Module, true
+}
+
+// getVersion asks the printer for the firmware versions of its modules.
+func (p *printer) getVersion() {
+	p.publish(map[string]map[string]any{
+		"info": {"command": "get_version"},
+	})
+}
+
+// collectFirmware exports the installed firmware of every module and the
+// state of firmware upgrades.
+func (collector *bambulabsCollector) collectFirmware(ch chan<- prometheus.Metric, labels []string, state *printerState) {
+	for _, module := range state.Modules {
+		ch <- prometheus.MustNewConstMetric(collector.moduleInfoMetric, prometheus.GaugeValue, 1, append(labels, module.Name, module.SwVer, module.HwVer, module.SN)...)
+	}
+
+	upgrade := state.Data.Print.UpgradeState
+	newVersions := []struct {
+		module  string
+		version string
+	}{
+		{"ota", upgrade.OtaNewVersionNumber},
+		{"ams", upgrade.AmsNewVersionNumber},
+		{"ahb", upgrade.AhbNewVersionNumber},
+	}
+	// Until get_version has answered there is nothing to compare against, so
+	// modules the printer hasn't listed are left out rather than reported as
+	// outdated.
+	for _, newVersion := range newVersions {
+		current, found := installed(state.Modules, newVersion.module, newVersion.version)
+		if !found {
+			continue
+		}
+		available := 0.0
+		if newVersion.version != "" && !current {
+			available = 1
+		}
+		ch <- prometheus.MustNewConstMetric(collector.firmwareUpdateMetric, prometheus.GaugeValue, available, append(labels, newVersion.module)...)
+	}
+
+	if upgrade.Status != "" {
+		ch <- prometheus.MustNewConstMetric(collector.upgradeStatusMetric, prometheus.GaugeValue, 1, append(labels, upgrade.Module, upgrade.Status)...)
+		ch <- prometheus.MustNewConstMetric(collector.upgradeProgressMetric, prometheus.GaugeValue, parseFloat(upgrade.Progress), append(labels, upgrade.Module)...)
+		ch <- prometheus.MustNewConstMetric(collector.upgradeErrorMetric, prometheus.GaugeValue, float64(upgrade.ErrCode), append(labels, upgrade.Module)...)
+	}
+}
+
+// installed reports whether every module named name, or name/<n> for
+// modules such as the AMS that can be fitted more than once, already runs
+// version. found is false when no such module is listed.
+func installed(modules []moduleVersion, name, version string) (current, found bool) {
+	current = true
+	for _, module := range modules {
+		if module.Name != name && !strings.HasPrefix(module.Name, name+"/") {
+			continue
+		}
+		found = true
+		if module.SwVer != version {
+			current = false
+		}
+	}
+	return current, found
+}
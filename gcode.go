@@ -0,0 +1,42 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// gcodeStates are the values of gcode_state. The index is what
+// bambulab_gcode_state_code reports.
+var gcodeStates = []string{"IDLE", "PREPARE", "RUNNING", "PAUSE", "FINISH", "FAILED", "SLICING"}
+
+// collectStateSet exports one series per known state, 1 for the current
+// one and 0 for the others. It returns the index of the current state, or
+// -1 if it is not one of the known states.
+func collectStateSet(ch chan<- prometheus.Metric, desc *prometheus.Desc, labels []string, states []string, current string) int {
+	index := -1
+	for i, state := range states {
+		value := 0.0
+		if state == current {
+			value = 1
+			index = i
+		}
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, append(labels, state)...)
+	}
+	// Keep states newer firmware may add visible instead of silently
+	// reporting every known state as 0.
+	if index == -1 {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, append(labels, current)...)
+	}
+	return index
+}
+
+// collectGcodeState exports gcode_state as a state set with one series per
+// state, plus the state as a number.
+func (collector *bambulabsCollector) collectGcodeState(ch chan<- prometheus.Metric, labels []string, report BambuLabsX1C) {
+	current := report.Print.GcodeState
+	if current == "" {
+		return
+	}
+
+	code := collectStateSet(ch, collector.gcodeStateMetric, labels, gcodeStates, current)
+	ch <- prometheus.MustNewConstMetric(collector.gcodeStateCodeMetric, prometheus.GaugeValue, float64(code), labels...)
+}
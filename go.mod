@@ -1,4 +1,4 @@
-module main
+module github.com/Aetrius/Bambulabs-Exporter
 
 go 1.18
 
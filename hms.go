@@ -0,0 +1,101 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// hmsDescriptionsJSON maps HMS codes, without the HMS_ prefix, to the text
+// Bambu Studio shows for them. It is generated from Bambu's published
+// English HMS list by tools/hmsgen. Codes missing from it still get a wiki
+// URL.
+//
+//go:generate go run ./tools/hmsgen -o hms_en.json
+//go:embed hms_en.json
+var hmsDescriptionsJSON []byte
+
+var hmsDescriptions map[string]string
+
+func init() {
+	if err := json.Unmarshal(hmsDescriptionsJSON, &hmsDescriptions); err != nil {
+		panic(fmt.Sprintf("parsing hms_en.json: %v", err))
+	}
+}
+
+// hmsModules names the module in the top byte of attr. The motion controller
+// (mc) also drives the heatbed, nozzle heater and fans.
+var hmsModules = map[int]string{
+	0x03: "mc",
+	0x05: "mainboard",
+	0x07: "ams",
+	0x08: "toolhead",
+	0x0C: "xcam",
+}
+
+// hmsSeverities names the severity in the top half of code.
+var hmsSeverities = map[int]string{
+	1: "fatal",
+	2: "serious",
+	3: "common",
+	4: "info",
+}
+
+// hmsCode is one health management entry of the report.
+type hmsCode struct {
+	Attr int
+	Code int
+}
+
+// ID returns the code the way Bambu Studio and the wiki write it,
+// HMS_0300_0100_0001_0001.
+func (h hmsCode) ID() string {
+	return "HMS_" + h.shortID()
+}
+
+func (h hmsCode) shortID() string {
+	return fmt.Sprintf("%04X_%04X_%04X_%04X", h.Attr>>16&0xFFFF, h.Attr&0xFFFF, h.Code>>16&0xFFFF, h.Code&0xFFFF)
+}
+
+func (h hmsCode) Module() string {
+	if module, ok := hmsModules[h.Attr>>24&0xFF]; ok {
+		return module
+	}
+	return "unknown"
+}
+
+func (h hmsCode) Severity() string {
+	if severity, ok := hmsSeverities[h.Code>>16&0xFFFF]; ok {
+		return severity
+	}
+	return "unknown"
+}
+
+func (h hmsCode) Description() string {
+	if description, ok := hmsDescriptions[h.shortID()]; ok {
+		return description
+	}
+	return "Unknown HMS error, see the wiki for details."
+}
+
+func (h hmsCode) URL() string {
+	return "https://wiki.bambulab.com/en/x1/troubleshooting/hmscode/" + h.shortID()
+}
+
+// collectHMS exports the active HMS entries. The printer drops an entry
+// from the list once it is resolved, so its series disappears as well.
+func (collector *bambulabsCollector) collectHMS(ch chan<- prometheus.Metric, labels []string, report BambuLabsX1C) {
+	seen := map[string]bool{}
+	for _, entry := range report.Print.Hms {
+		code := hmsCode{Attr: entry.Attr, Code: entry.Code}
+		if seen[code.ID()] {
+			continue
+		}
+		seen[code.ID()] = true
+
+		ch <- prometheus.MustNewConstMetric(collector.hmsErrorMetric, prometheus.GaugeValue, 1, append(labels, code.ID(), code.Module(), code.Severity())...)
+		ch <- prometheus.MustNewConstMetric(collector.hmsErrorInfoMetric, prometheus.GaugeValue, 1, append(labels, code.ID(), code.Description(), code.URL())...)
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// trayExternal and trayUnloaded are the tray_now and tray_tar values for
+	// the external spool holder and for no filament loaded.
+	trayExternal = 254
+	trayUnloaded = 255
+)
+
+// jobState is what the exporter has seen of the current job across reports.
+type jobState struct {
+	Key             string
+	TaskID          string
+	FilamentChanges int
+
+	// Stage is the last stg_cur seen and StageStart when the printer entered
+	// it, zero when that happened before the exporter connected.
+	Stage      int
+	StageStart time.Time
+
+	// Layer is the last layer_num seen and LayerStart when the printer
+	// started it, zero when the exporter didn't see the layer begin.
+	Layer      int
+	LayerStart time.Time
+
+	// lastTray is the last tray filament was loaded from, -1 before the
+	// first load of the job.
+	lastTray   int
+	stageKnown bool
+	layerKnown bool
+}
+
+// jobObservers are the histograms of one printer that are fed as jobs
+// progress. They live across reports, unlike the rest of the metrics which
+// are built from the current report on every scrape.
+type jobObservers struct {
+	stageDuration prometheus.ObserverVec
+	layerDuration prometheus.ObserverVec
+}
+
+// jobHistograms are shared by all printers and collected with the rest of
+// the metrics.
+type jobHistograms struct {
+	stageDuration *prometheus.HistogramVec
+	layerDuration *prometheus.HistogramVec
+}
+
+func newJobHistograms() *jobHistograms {
+	return &jobHistograms{
+		stageDuration: newStageDurationHistogram(),
+		layerDuration: newLayerDurationHistogram(),
+	}
+}
+
+// forPrinter returns the observers of one printer.
+func (histograms *jobHistograms) forPrinter(config printerConfig) jobObservers {
+	labels := prometheus.Labels{"printer": config.Name, "serial": config.Serial}
+	return jobObservers{
+		stageDuration: histograms.stageDuration.MustCurryWith(labels),
+		layerDuration: histograms.layerDuration.MustCurryWith(labels),
+	}
+}
+
+func (histograms *jobHistograms) describe(ch chan<- *prometheus.Desc) {
+	histograms.stageDuration.Describe(ch)
+	histograms.layerDuration.Describe(ch)
+}
+
+func (histograms *jobHistograms) collect(ch chan<- prometheus.Metric) {
+	histograms.stageDuration.Collect(ch)
+	histograms.layerDuration.Collect(ch)
+}
+
+// observe feeds the histograms with what changed between two job states.
+func (observers jobObservers) observe(prev, job jobState) {
+	observers.observeStage(prev, job)
+	observers.observeLayer(prev, job)
+}
+
+// jobKey identifies a job. Local prints don't always have a task ID, so the
+// start time is part of it.
+func jobKey(report BambuLabsX1C) string {
+	status := report.Print
+	if status.TaskID == "" && status.GcodeStartTime == "" {
+		return ""
+	}
+	return status.TaskID + "/" + status.GcodeStartTime
+}
+
+// next returns the job state after a new report. The stage carries over
+// from one job to the next since the printer moves through them regardless.
+func (job jobState) next(report BambuLabsX1C, received time.Time) jobState {
+	if key := jobKey(report); key != job.Key {
+		job = jobState{
+			Key:        key,
+			TaskID:     report.Print.TaskID,
+			Stage:      job.Stage,
+			StageStart: job.StageStart,
+			lastTray:   -1,
+			stageKnown: job.stageKnown,
+		}
+	}
+	job = job.stageTransition(report, received)
+	job = job.layerTransition(report, received)
+
+	tray, err := strconv.Atoi(report.Print.Ams.TrayNow)
+	if err != nil || tray == trayUnloaded {
+		return job
+	}
+	if job.lastTray != -1 && tray != job.lastTray {
+		job.FilamentChanges++
+	}
+	job.lastTray = tray
+	return job
+}
+
+// trayLabelValues splits a tray_now or tray_tar value into AMS and tray
+// numbers. The external spool reports as AMS "external".
+func trayLabelValues(value int) (string, string) {
+	if value == trayExternal {
+		return "external", "0"
+	}
+	return strconv.Itoa(value / 4), strconv.Itoa(value % 4)
+}
+
+// collectTrays exports the tray filament is loaded from and the one the
+// printer is switching to.
+func (collector *bambulabsCollector) collectTrays(ch chan<- prometheus.Metric, labels []string, state *printerState) {
+	ams := state.Data.Print.Ams
+	trays := []struct {
+		value    string
+		desc     *prometheus.Desc
+		descCode *prometheus.Desc
+	}{
+		{ams.TrayNow, collector.amsActiveTrayMetric, collector.amsActiveTrayCodeMetric},
+		{ams.TrayTar, collector.amsTargetTrayMetric, collector.amsTargetTrayCodeMetric},
+	}
+	for _, tray := range trays {
+		value, err := strconv.Atoi(tray.value)
+		if err != nil {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(tray.descCode, prometheus.GaugeValue, float64(value), labels...)
+		if value == trayUnloaded {
+			continue
+		}
+		amsNumber, trayNumber := trayLabelValues(value)
+		ch <- prometheus.MustNewConstMetric(tray.desc, prometheus.GaugeValue, 1, append(labels, amsNumber, trayNumber)...)
+	}
+
+	if state.Job.Key != "" {
+		ch <- prometheus.MustNewConstMetric(collector.amsFilamentChangesMetric, prometheus.CounterValue, float64(state.Job.FilamentChanges), append(labels, state.Job.TaskID)...)
+	}
+}
+
+// collectJob exports which job the printer is running, so dashboards can
+// title a print and join progress metrics to it.
+func (collector *bambulabsCollector) collectJob(ch chan<- prometheus.Metric, labels []string, report BambuLabsX1C) {
+	status := report.Print
+	if status.GcodeFile == "" && status.SubtaskName == "" && status.TaskID == "" {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(collector.printJobInfoMetric, prometheus.GaugeValue, 1,
+		append(labels, status.GcodeFile, status.SubtaskName, status.TaskID, status.PrintType, status.ProjectID, status.ProfileID)...)
+
+	// gcode_start_time is sent as a string of Unix seconds, "0" when unset.
+	if start, err := strconv.ParseInt(status.GcodeStartTime, 10, 64); err == nil && start > 0 {
+		ch <- prometheus.MustNewConstMetric(collector.printJobStartMetric, prometheus.GaugeValue, float64(start), labels...)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collectLayerProgress exports how far the print is by layer. Unlike
+// mc_percent it doesn't run ahead on prints whose top layers are small.
+func (collector *bambulabsCollector) collectLayerProgress(ch chan<- prometheus.Metric, labels []string, report BambuLabsX1C) {
+	status := report.Print
+	if status.TotalLayerNum <= 0 {
+		return
+	}
+	ratio := float64(status.LayerNum) / float64(status.TotalLayerNum)
+	if ratio > 1 {
+		ratio = 1
+	}
+	ch <- prometheus.MustNewConstMetric(collector.layerProgressMetric, prometheus.GaugeValue, ratio, labels...)
+}
+
+// layerDurationBuckets go from 2 seconds to about 17 minutes.
+var layerDurationBuckets = prometheus.ExponentialBuckets(2, 2, 10)
+
+func newLayerDurationHistogram() *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "bambulab_layer_duration_seconds",
+		Help:    "Time taken to print each layer",
+		Buckets: layerDurationBuckets,
+	}, printerLabelNames())
+}
+
+// layerTransition moves the job to the layer in the report. A layer only gets
+// a start time when the exporter saw the previous one end.
+func (job jobState) layerTransition(report BambuLabsX1C, received time.Time) jobState {
+	layer := report.Print.LayerNum
+	if layer == job.Layer && job.layerKnown {
+		return job
+	}
+	if job.layerKnown {
+		job.LayerStart = received
+	}
+	job.Layer = layer
+	job.layerKnown = true
+	return job
+}
+
+// observeLayer records the duration of a layer once the next one starts.
+// Layer 0 is the preparation before the first layer and is left out.
+func (observers jobObservers) observeLayer(prev, job jobState) {
+	if job.Key != prev.Key || prev.LayerStart.IsZero() || prev.Layer == 0 {
+		return
+	}
+	if job.Layer <= prev.Layer {
+		return
+	}
+	observers.layerDuration.WithLabelValues().Observe(job.LayerStart.Sub(prev.LayerStart).Seconds())
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// lightModes are the modes of a lights_report entry.
+var lightModes = []string{"on", "off", "flashing"}
+
+// collectLights exports the chamber and work lights.
+func (collector *bambulabsCollector) collectLights(ch chan<- prometheus.Metric, labels []string, report BambuLabsX1C) {
+	for _, light := range report.Print.LightsReport {
+		lightLabels := append(labels[:2:2], light.Node)
+
+		on := 0.0
+		if light.Mode != "off" {
+			on = 1
+		}
+		ch <- prometheus.MustNewConstMetric(collector.lightOnMetric, prometheus.GaugeValue, on, lightLabels...)
+		collectStateSet(ch, collector.lightModeMetric, lightLabels, lightModes, light.Mode)
+	}
+}
+
+// collectCamera exports whether the camera records prints and timelapses.
+// Settings the printer doesn't report are left out.
+func (collector *bambulabsCollector) collectCamera(ch chan<- prometheus.Metric, labels []string, report BambuLabsX1C) {
+	ipcam := report.Print.Ipcam
+	if ipcam.IpcamRecord != "" {
+		ch <- prometheus.MustNewConstMetric(collector.cameraRecordingMetric, prometheus.GaugeValue, enabledValue(ipcam.IpcamRecord), labels...)
+	}
+	if ipcam.Timelapse != "" {
+		ch <- prometheus.MustNewConstMetric(collector.cameraTimelapseMetric, prometheus.GaugeValue, enabledValue(ipcam.Timelapse), labels...)
+	}
+	if ipcam.Resolution != "" {
+		ch <- prometheus.MustNewConstMetric(collector.cameraResolutionMetric, prometheus.GaugeValue, 1, append(labels, ipcam.Resolution)...)
+	}
+}
+
+// enabledValue reads the "enable"/"disable" strings of the ipcam block.
+func enabledValue(s string) float64 {
+	if s == "enable" {
+		return 1
+	}
+	return 0
+}
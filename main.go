@@ -1,80 +1,99 @@
 package main
 
 import (
-	"crypto/tls"
-	"encoding/json"
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"reflect"
-	"strconv"
-	"strings"
+	"os/signal"
+	"syscall"
 	"time"
 
-	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/joho/godotenv"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-var data BambuLabsX1C
-var datav2 BambuLabsX1C
-
-var username string
-var password string
-var broker string
-var mqtt_topic string
-
-// var humidity float64
-// var ams_temp float64
-// var ams_bed_temp float64
-var layer_number float64
-var print_error float64
-
-var wifi_signal float64
-
-var big_fan1_speed float64
-var big_fan2_speed float64
-var chamber_temper float64
-var cooling_fan_speed float64
-var fail_reason float64
-var fan_gear float64
-
-// var gcode_state string
-var mc_percent float64
-var mc_print_error_code float64
-var mc_print_stage float64
-var mc_print_sub_stage float64
-var mc_remaining_time float64
-var nozzle_target_temper float64
-var nozzle_temper float64
-
-var unmarshal bool
-
 type bambulabsCollector struct {
-	amsHumidityMetric     *prometheus.Desc
-	amsTempMetric         *prometheus.Desc
-	amsBedTempMetric      *prometheus.Desc
-	amsColorMetric        *prometheus.Desc //Custom color metric with multiple labels
-	layerNumberMetric     *prometheus.Desc
-	printErrorMetric      *prometheus.Desc
-	wifiSignalMetric      *prometheus.Desc
-	bigFan1SpeedMetric    *prometheus.Desc
-	bigFan2SpeedMetric    *prometheus.Desc
-	chamberTemperMetric   *prometheus.Desc
-	coolingFanSpeedMetric *prometheus.Desc
-	failReasonMetric      *prometheus.Desc
-	fanGearMetric         *prometheus.Desc
-	//gCodeStateMetric       *prometheus.Desc
-	mcPercentMetric          *prometheus.Desc
-	mcPrintErrorCodeMetric   *prometheus.Desc
-	mcPrintStageMetric       *prometheus.Desc
-	mcPrintSubStageMetric    *prometheus.Desc
-	mcRemainingTimeMetric    *prometheus.Desc
-	nozzleTargetTemperMetric *prometheus.Desc
-	nozzleTemperMetric       *prometheus.Desc
+	printers        []*printer
+	histograms      *jobHistograms
+	maxReportAge    time.Duration
+	keepStaleSeries bool
+
+	amsHumidityMetric *prometheus.Desc
+	amsTempMetric     *prometheus.Desc
+	amsBedTempMetric  *prometheus.Desc
+	amsColorMetric    *prometheus.Desc //Custom color metric with multiple labels
+
+	amsTrayRemainMetric        *prometheus.Desc
+	amsTrayWeightMetric        *prometheus.Desc
+	amsTrayDiameterMetric      *prometheus.Desc
+	amsTrayNozzleTempMinMetric *prometheus.Desc
+	amsTrayNozzleTempMaxMetric *prometheus.Desc
+	amsTrayDryingTempMetric    *prometheus.Desc
+	amsTrayDryingTimeMetric    *prometheus.Desc
+	amsTrayInfoMetric          *prometheus.Desc
+	amsPresentMetric           *prometheus.Desc
+	amsTrayLoadedMetric        *prometheus.Desc
+	amsTrayIsBambuMetric       *prometheus.Desc
+	amsTrayRFIDReadingMetric   *prometheus.Desc
+	amsTrayRFIDReadDoneMetric  *prometheus.Desc
+	amsActiveTrayMetric        *prometheus.Desc
+	amsActiveTrayCodeMetric    *prometheus.Desc
+	amsTargetTrayMetric        *prometheus.Desc
+	amsTargetTrayCodeMetric    *prometheus.Desc
+	amsFilamentChangesMetric   *prometheus.Desc
+
+	// Gauges for the numeric fields of the report, see newFieldMetrics.
+	fieldMetrics []fieldMetric
+
+	gcodeStateMetric     *prometheus.Desc
+	gcodeStateCodeMetric *prometheus.Desc
+	hmsErrorMetric       *prometheus.Desc
+	hmsErrorInfoMetric   *prometheus.Desc
+	printJobInfoMetric   *prometheus.Desc
+	printJobStartMetric  *prometheus.Desc
+
+	moduleInfoMetric      *prometheus.Desc
+	firmwareUpdateMetric  *prometheus.Desc
+	upgradeStatusMetric   *prometheus.Desc
+	upgradeProgressMetric *prometheus.Desc
+	upgradeErrorMetric    *prometheus.Desc
+
+	uploadStatusMetric        *prometheus.Desc
+	uploadProgressMetric      *prometheus.Desc
+	uploadFileSizeMetric      *prometheus.Desc
+	uploadBytesDoneMetric     *prometheus.Desc
+	uploadSpeedMetric         *prometheus.Desc
+	uploadTimeRemainingMetric *prometheus.Desc
+
+	xcamDetectorMetric    *prometheus.Desc
+	xcamSensitivityMetric *prometheus.Desc
+
+	lightOnMetric          *prometheus.Desc
+	lightModeMetric        *prometheus.Desc
+	cameraRecordingMetric  *prometheus.Desc
+	cameraTimelapseMetric  *prometheus.Desc
+	cameraResolutionMetric *prometheus.Desc
+
+	speedLevelMetric        *prometheus.Desc
+	printStageMetric        *prometheus.Desc
+	printStagePlannedMetric *prometheus.Desc
+	layerProgressMetric     *prometheus.Desc
+	fanPercentMetric        *prometheus.Desc
+	fanLevelMetric          *prometheus.Desc
+	fanGearMetric           *prometheus.Desc
+
+	mqttConnectedMetric        *prometheus.Desc
+	mqttReconnectsMetric       *prometheus.Desc
+	mqttLastConnectMetric      *prometheus.Desc
+	mqttConnectionErrorsMetric *prometheus.Desc
+	mqttIDCollisionsMetric     *prometheus.Desc
+
+	printerUpMetric   *prometheus.Desc
+	lastMessageMetric *prometheus.Desc
 }
 
 func env(key string) string {
@@ -90,87 +109,253 @@ func env(key string) string {
 
 // You must create a constructor for you collector that
 // initializes every descriptor and returns a pointer to the collector
-func newBambulabsCollector() *bambulabsCollector {
+func newBambulabsCollector(printers []*printer, histograms *jobHistograms, config exporterConfig) *bambulabsCollector {
 	return &bambulabsCollector{
+		printers:        printers,
+		histograms:      histograms,
+		maxReportAge:    time.Duration(config.MaxReportAge),
+		keepStaleSeries: config.KeepStaleSeries,
+
 		amsHumidityMetric: prometheus.NewDesc("ams_humidity_metric",
 			"humidity of the ams",
-			[]string{"ams_number"}, nil,
+			printerLabelNames("ams_number"), nil,
 		),
 		amsTempMetric: prometheus.NewDesc("ams_temp_metric",
 			"temperature of the ams",
-			[]string{"ams_number"}, nil,
+			printerLabelNames("ams_number"), nil,
 		),
 		amsColorMetric: prometheus.NewDesc("ams_tray_color_metric",
 			"ID of the ams with color hex values",
-			[]string{"ams_number", "tray_number", "tray_color", "tray_type"}, nil,
+			printerLabelNames("ams_number", "tray_number", "tray_color", "tray_type"), nil,
 		),
 		amsBedTempMetric: prometheus.NewDesc("ams_bed_temp_metric",
-			"temperature of the ams bed",
-			[]string{"ams_number", "tray_number"}, nil,
+			"recommended bed temperature for the filament in the ams tray",
+			printerLabelNames("ams_number", "tray_number"), nil,
+		),
+		amsTrayRemainMetric: prometheus.NewDesc("bambulab_ams_tray_remain_percent",
+			"Filament left on the spool in percent, for spools the AMS can measure",
+			printerLabelNames("ams_number", "tray_number"), nil,
+		),
+		amsTrayWeightMetric: prometheus.NewDesc("bambulab_ams_tray_weight_grams",
+			"Filament weight of a full spool in grams",
+			printerLabelNames("ams_number", "tray_number"), nil,
+		),
+		amsTrayDiameterMetric: prometheus.NewDesc("bambulab_ams_tray_diameter_mm",
+			"Filament diameter in mm",
+			printerLabelNames("ams_number", "tray_number"), nil,
+		),
+		amsTrayNozzleTempMinMetric: prometheus.NewDesc("bambulab_ams_tray_nozzle_temp_min_celsius",
+			"Minimum nozzle temperature for the filament",
+			printerLabelNames("ams_number", "tray_number"), nil,
+		),
+		amsTrayNozzleTempMaxMetric: prometheus.NewDesc("bambulab_ams_tray_nozzle_temp_max_celsius",
+			"Maximum nozzle temperature for the filament",
+			printerLabelNames("ams_number", "tray_number"), nil,
+		),
+		amsTrayDryingTempMetric: prometheus.NewDesc("bambulab_ams_tray_drying_temp_celsius",
+			"Recommended drying temperature for the filament",
+			printerLabelNames("ams_number", "tray_number"), nil,
+		),
+		amsTrayDryingTimeMetric: prometheus.NewDesc("bambulab_ams_tray_drying_time_seconds",
+			"Recommended drying time for the filament in seconds",
+			printerLabelNames("ams_number", "tray_number"), nil,
+		),
+		amsTrayInfoMetric: prometheus.NewDesc("bambulab_ams_tray_info",
+			"Spool details read from the filament RFID tag",
+			printerLabelNames("ams_number", "tray_number", "tray_sub_brands", "tray_id_name", "tray_info_idx", "tag_uid", "tray_uuid"), nil,
+		),
+		amsPresentMetric: prometheus.NewDesc("bambulab_ams_present",
+			"1 if the AMS unit is connected",
+			printerLabelNames("ams_number"), nil,
+		),
+		amsTrayLoadedMetric: prometheus.NewDesc("bambulab_ams_tray_loaded",
+			"1 if a spool is in the AMS tray",
+			printerLabelNames("ams_number", "tray_number"), nil,
+		),
+		amsTrayIsBambuMetric: prometheus.NewDesc("bambulab_ams_tray_is_bambu_filament",
+			"1 if the spool in the AMS tray is Bambu filament with an RFID tag",
+			printerLabelNames("ams_number", "tray_number"), nil,
+		),
+		amsTrayRFIDReadingMetric: prometheus.NewDesc("bambulab_ams_tray_rfid_reading",
+			"1 while the AMS is reading the RFID tag of the spool",
+			printerLabelNames("ams_number", "tray_number"), nil,
+		),
+		amsTrayRFIDReadDoneMetric: prometheus.NewDesc("bambulab_ams_tray_rfid_read_done",
+			"1 once the AMS has read the RFID tag of the spool",
+			printerLabelNames("ams_number", "tray_number"), nil,
+		),
+		amsActiveTrayMetric: prometheus.NewDesc("bambulab_ams_active_tray",
+			"1 for the tray filament is loaded from, ams_number is external for the external spool",
+			printerLabelNames("ams_number", "tray_number"), nil,
+		),
+		amsActiveTrayCodeMetric: prometheus.NewDesc("bambulab_ams_active_tray_code",
+			"tray_now as sent by the printer: ams_number*4+tray_number, 254 external spool, 255 unloaded",
+			printerLabelNames(), nil,
+		),
+		amsTargetTrayMetric: prometheus.NewDesc("bambulab_ams_target_tray",
+			"1 for the tray the printer is switching to, ams_number is external for the external spool",
+			printerLabelNames("ams_number", "tray_number"), nil,
+		),
+		amsTargetTrayCodeMetric: prometheus.NewDesc("bambulab_ams_target_tray_code",
+			"tray_tar as sent by the printer: ams_number*4+tray_number, 254 external spool, 255 unloaded",
+			printerLabelNames(), nil,
+		),
+		amsFilamentChangesMetric: prometheus.NewDesc("bambulab_ams_filament_changes_total",
+			"Number of times the loaded tray changed during the current job",
+			printerLabelNames("task_id"), nil,
+		),
+		fieldMetrics: newFieldMetrics(),
+		gcodeStateMetric: prometheus.NewDesc("bambulab_gcode_state",
+			"1 for the current gcode_state of the printer, 0 for the others",
+			printerLabelNames("state"), nil,
+		),
+		gcodeStateCodeMetric: prometheus.NewDesc("bambulab_gcode_state_code",
+			"gcode_state as a number: 0 IDLE, 1 PREPARE, 2 RUNNING, 3 PAUSE, 4 FINISH, 5 FAILED, 6 SLICING, -1 unknown",
+			printerLabelNames(), nil,
+		),
+		hmsErrorMetric: prometheus.NewDesc("bambulab_hms_error",
+			"Active HMS (health management) errors reported by the printer",
+			printerLabelNames("code", "module", "severity"), nil,
+		),
+		hmsErrorInfoMetric: prometheus.NewDesc("bambulab_hms_error_info",
+			"Description and wiki URL of active HMS errors",
+			printerLabelNames("code", "description", "url"), nil,
+		),
+		printJobInfoMetric: prometheus.NewDesc("bambulab_print_job_info",
+			"The job the printer is running or last ran",
+			printerLabelNames("gcode_file", "subtask_name", "task_id", "print_type", "project_id", "profile_id"), nil,
+		),
+		printJobStartMetric: prometheus.NewDesc("bambulab_print_job_start_timestamp_seconds",
+			"Unix time the current or last job started",
+			printerLabelNames(), nil,
+		),
+		moduleInfoMetric: prometheus.NewDesc("bambulab_module_info",
+			"Firmware and hardware version of each printer module, from get_version",
+			printerLabelNames("module", "sw_ver", "hw_ver", "sn"), nil,
+		),
+		firmwareUpdateMetric: prometheus.NewDesc("bambulab_firmware_update_available",
+			"1 if the printer offers a firmware version the module is not running",
+			printerLabelNames("module"), nil,
+		),
+		upgradeStatusMetric: prometheus.NewDesc("bambulab_firmware_upgrade_status",
+			"Status of the current or last firmware upgrade",
+			printerLabelNames("module", "status"), nil,
+		),
+		upgradeProgressMetric: prometheus.NewDesc("bambulab_firmware_upgrade_progress_percent",
+			"Progress of the current or last firmware upgrade",
+			printerLabelNames("module"), nil,
+		),
+		upgradeErrorMetric: prometheus.NewDesc("bambulab_firmware_upgrade_error_code",
+			"Error code of the current or last firmware upgrade, 0 without error",
+			printerLabelNames("module"), nil,
+		),
+		uploadStatusMetric: prometheus.NewDesc("bambulab_upload_status",
+			"1 for the current status of the file upload to the printer, 0 for the others",
+			printerLabelNames("status"), nil,
+		),
+		uploadProgressMetric: prometheus.NewDesc("bambulab_upload_progress_percent",
+			"Progress of the file upload to the printer",
+			printerLabelNames(), nil,
+		),
+		uploadFileSizeMetric: prometheus.NewDesc("bambulab_upload_file_size_bytes",
+			"Size of the file being uploaded to the printer in bytes",
+			printerLabelNames(), nil,
+		),
+		uploadBytesDoneMetric: prometheus.NewDesc("bambulab_upload_bytes_done",
+			"Bytes of the file uploaded to the printer so far",
+			printerLabelNames(), nil,
+		),
+		uploadSpeedMetric: prometheus.NewDesc("bambulab_upload_speed",
+			"Upload speed as reported by the printer",
+			printerLabelNames(), nil,
+		),
+		uploadTimeRemainingMetric: prometheus.NewDesc("bambulab_upload_time_remaining_seconds",
+			"Estimated time left for the file upload",
+			printerLabelNames(), nil,
+		),
+		xcamDetectorMetric: prometheus.NewDesc("bambulab_xcam_detector_enabled",
+			"1 if the camera based detector is turned on",
+			printerLabelNames("detector"), nil,
 		),
-		layerNumberMetric: prometheus.NewDesc("layer_number_metric",
-			"layer number of the print head in gcode",
-			nil, nil,
+		xcamSensitivityMetric: prometheus.NewDesc("bambulab_xcam_halt_print_sensitivity_info",
+			"Sensitivity at which a detected failure halts the print",
+			printerLabelNames("sensitivity"), nil,
 		),
-		printErrorMetric: prometheus.NewDesc("print_error_metric",
-			"Print error int",
-			nil, nil,
+		lightOnMetric: prometheus.NewDesc("bambulab_light_on",
+			"1 if the light is on or flashing",
+			printerLabelNames("node"), nil,
 		),
-		wifiSignalMetric: prometheus.NewDesc("wifi_signal_metric",
-			"Wifi signal in dBm",
-			nil, nil,
+		lightModeMetric: prometheus.NewDesc("bambulab_light_mode",
+			"1 for the current mode of the light, 0 for the others",
+			printerLabelNames("node", "mode"), nil,
 		),
-		bigFan1SpeedMetric: prometheus.NewDesc("big_fan1_speed_metric",
-			"Big Fan 1 Speed",
-			nil, nil,
+		cameraRecordingMetric: prometheus.NewDesc("bambulab_camera_recording",
+			"1 if the camera records prints",
+			printerLabelNames(), nil,
 		),
-		bigFan2SpeedMetric: prometheus.NewDesc("big_fan2_speed_metric",
-			"Big Fan 2 Speed",
-			nil, nil,
+		cameraTimelapseMetric: prometheus.NewDesc("bambulab_camera_timelapse_enabled",
+			"1 if timelapse recording is turned on",
+			printerLabelNames(), nil,
 		),
-		chamberTemperMetric: prometheus.NewDesc("chamber_temper_metric",
-			"Chamber Temperature of Printer",
-			nil, nil,
+		cameraResolutionMetric: prometheus.NewDesc("bambulab_camera_resolution_info",
+			"Resolution the camera records at",
+			printerLabelNames("resolution"), nil,
 		),
-		coolingFanSpeedMetric: prometheus.NewDesc("cooling_fan_speed_metric",
-			"Cooling Fan Speed",
-			nil, nil,
+		printStageMetric: prometheus.NewDesc("bambulab_print_stage",
+			"1 for the stage the printer is in",
+			printerLabelNames("stage"), nil,
 		),
-		failReasonMetric: prometheus.NewDesc("fail_reason_metric",
-			"Print Failure Reason",
-			nil, nil,
+		printStagePlannedMetric: prometheus.NewDesc("bambulab_print_stage_planned",
+			"1 for each stage the printer planned for the current job",
+			printerLabelNames("stage"), nil,
 		),
-		fanGearMetric: prometheus.NewDesc("fan_gear_metric",
-			"Fan Gear",
-			nil, nil,
+		layerProgressMetric: prometheus.NewDesc("bambulab_layer_progress_ratio",
+			"Printed layers as a fraction of the total layers of the print",
+			printerLabelNames(), nil,
 		),
-		mcPercentMetric: prometheus.NewDesc("mc_percent_metric",
-			"Percentage of Progress of print",
-			nil, nil,
+		fanPercentMetric: prometheus.NewDesc("bambulab_fan_speed_percent",
+			"Speed of the fan in percent",
+			printerLabelNames("fan"), nil,
 		),
-		mcPrintErrorCodeMetric: prometheus.NewDesc("mc_print_error_code_metric",
-			"Print Progress Error Code",
-			nil, nil,
+		fanLevelMetric: prometheus.NewDesc("bambulab_fan_speed_level",
+			"Speed of the fan as the printer reports it, 0 to 15",
+			printerLabelNames("fan"), nil,
 		),
-		mcPrintStageMetric: prometheus.NewDesc("mc_print_stage_metric",
-			"Print Progress Stage",
-			nil, nil,
+		fanGearMetric: prometheus.NewDesc("bambulab_fan_gear",
+			"Speed of the fan from fan_gear, 0 to 255",
+			printerLabelNames("fan"), nil,
 		),
-		mcPrintSubStageMetric: prometheus.NewDesc("mc_print_sub_stage_metric",
-			"Print Progress Sub Stage",
-			nil, nil,
+		speedLevelMetric: prometheus.NewDesc("bambulab_print_speed_level",
+			"1 for the current speed profile (silent, standard, sport, ludicrous), 0 for the others",
+			printerLabelNames("level"), nil,
 		),
-		mcRemainingTimeMetric: prometheus.NewDesc("mc_remaining_time_metric",
-			"Print Progress Remaining Time in minutes",
-			nil, nil,
+		mqttConnectedMetric: prometheus.NewDesc("bambulab_mqtt_connected",
+			"1 if the MQTT connection to the printer is up",
+			printerLabelNames(), nil,
 		),
-		nozzleTargetTemperMetric: prometheus.NewDesc("nozzle_target_temper_metric",
-			"Nozzle Target Temperature Metric",
-			nil, nil,
+		mqttReconnectsMetric: prometheus.NewDesc("bambulab_mqtt_reconnects_total",
+			"Number of times the MQTT connection was re-established",
+			printerLabelNames(), nil,
 		),
-		nozzleTemperMetric: prometheus.NewDesc("nozzle_temper_metric",
-			"Nozzle Temperature Metric",
-			nil, nil,
+		mqttLastConnectMetric: prometheus.NewDesc("bambulab_mqtt_last_connect_timestamp_seconds",
+			"Unix time of the last successful MQTT connect",
+			printerLabelNames(), nil,
+		),
+		mqttConnectionErrorsMetric: prometheus.NewDesc("bambulab_mqtt_connection_errors_total",
+			"Number of failed MQTT connects and lost connections by reason",
+			printerLabelNames("reason"), nil,
+		),
+		mqttIDCollisionsMetric: prometheus.NewDesc("bambulab_mqtt_client_id_collisions_total",
+			"Number of sessions the broker rejected or dropped right after connecting, likely because another client uses the same client ID",
+			printerLabelNames(), nil,
+		),
+		printerUpMetric: prometheus.NewDesc("bambulab_printer_up",
+			"1 if the printer sent a report within the max report age",
+			printerLabelNames(), nil,
+		),
+		lastMessageMetric: prometheus.NewDesc("bambulab_last_message_timestamp_seconds",
+			"Unix time of the last report received from the printer",
+			printerLabelNames(), nil,
 		),
 	}
 }
@@ -184,223 +369,213 @@ func (collector *bambulabsCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- collector.amsTempMetric
 	ch <- collector.amsColorMetric
 	ch <- collector.amsBedTempMetric
-	ch <- collector.layerNumberMetric
-	ch <- collector.printErrorMetric
-	ch <- collector.wifiSignalMetric
-	ch <- collector.bigFan1SpeedMetric
-	ch <- collector.bigFan2SpeedMetric
-	ch <- collector.chamberTemperMetric
-	ch <- collector.coolingFanSpeedMetric
-	ch <- collector.failReasonMetric
+	ch <- collector.amsTrayRemainMetric
+	ch <- collector.amsTrayWeightMetric
+	ch <- collector.amsTrayDiameterMetric
+	ch <- collector.amsTrayNozzleTempMinMetric
+	ch <- collector.amsTrayNozzleTempMaxMetric
+	ch <- collector.amsTrayDryingTempMetric
+	ch <- collector.amsTrayDryingTimeMetric
+	ch <- collector.amsTrayInfoMetric
+	ch <- collector.amsPresentMetric
+	ch <- collector.amsTrayLoadedMetric
+	ch <- collector.amsTrayIsBambuMetric
+	ch <- collector.amsTrayRFIDReadingMetric
+	ch <- collector.amsTrayRFIDReadDoneMetric
+	ch <- collector.amsActiveTrayMetric
+	ch <- collector.amsActiveTrayCodeMetric
+	ch <- collector.amsTargetTrayMetric
+	ch <- collector.amsTargetTrayCodeMetric
+	ch <- collector.amsFilamentChangesMetric
+	for _, metric := range collector.fieldMetrics {
+		ch <- metric.desc
+	}
+	ch <- collector.gcodeStateMetric
+	ch <- collector.gcodeStateCodeMetric
+	ch <- collector.hmsErrorMetric
+	ch <- collector.hmsErrorInfoMetric
+	ch <- collector.printJobInfoMetric
+	ch <- collector.printJobStartMetric
+	ch <- collector.moduleInfoMetric
+	ch <- collector.firmwareUpdateMetric
+	ch <- collector.upgradeStatusMetric
+	ch <- collector.upgradeProgressMetric
+	ch <- collector.upgradeErrorMetric
+	ch <- collector.uploadStatusMetric
+	ch <- collector.uploadProgressMetric
+	ch <- collector.uploadFileSizeMetric
+	ch <- collector.uploadBytesDoneMetric
+	ch <- collector.uploadSpeedMetric
+	ch <- collector.uploadTimeRemainingMetric
+	ch <- collector.xcamDetectorMetric
+	ch <- collector.xcamSensitivityMetric
+	ch <- collector.lightOnMetric
+	ch <- collector.lightModeMetric
+	ch <- collector.cameraRecordingMetric
+	ch <- collector.cameraTimelapseMetric
+	ch <- collector.cameraResolutionMetric
+	ch <- collector.speedLevelMetric
+	ch <- collector.printStageMetric
+	ch <- collector.printStagePlannedMetric
+	ch <- collector.layerProgressMetric
+	ch <- collector.fanPercentMetric
+	ch <- collector.fanLevelMetric
 	ch <- collector.fanGearMetric
-	ch <- collector.mcPercentMetric
-	ch <- collector.mcPrintErrorCodeMetric
-	ch <- collector.mcPrintStageMetric
-	ch <- collector.mcPrintSubStageMetric
-	ch <- collector.mcRemainingTimeMetric
-	ch <- collector.nozzleTargetTemperMetric
-	ch <- collector.nozzleTemperMetric
+	collector.histograms.describe(ch)
+	ch <- collector.mqttConnectedMetric
+	ch <- collector.mqttReconnectsMetric
+	ch <- collector.mqttLastConnectMetric
+	ch <- collector.mqttConnectionErrorsMetric
+	ch <- collector.mqttIDCollisionsMetric
+	ch <- collector.printerUpMetric
+	ch <- collector.lastMessageMetric
 }
 
 // Collect implements required collect function for all prometheus collectors
 func (collector *bambulabsCollector) Collect(ch chan<- prometheus.Metric) {
-
-	//var broker = broker
-	var port = 8883
-	opts := mqtt.NewClientOptions()
-	opts.AddBroker(fmt.Sprintf("ssl://%s:%d", broker, port))
-	opts.SetClientID("go_mqtt_client")
-	opts.SetUsername(username)
-	opts.SetPassword(password)
-	opts.SetDefaultPublishHandler(messagePubHandler)
-	opts.OnConnect = connectHandler
-	opts.OnConnectionLost = connectLostHandler
-
-	opts.SetTLSConfig(newTLSConfig())
-	client := mqtt.NewClient(opts)
-	token := client.Connect()
-	// if token.Wait() && token.Error() != nil {
-	// 	panic(token.Error())
-	// }
-
-	sub(client)
-	//defer client.Disconnect(250)
-	//defer token.Done()
-	token.Wait()
-	time.Sleep(time.Second)
-	defer client.Disconnect(250)
-	defer token.Done()
-	//fmt.Printf("\nHumidity: %s", data.Print.Ams.Ams[0].Humidity)
-
-	if reflect.ValueOf(data).IsZero() == true {
-		//Loop through the AMS
-		for x := 0; x < len(datav2.Print.Ams.Ams); x++ {
-
-			ams_temp, _ := strconv.ParseFloat(datav2.Print.Ams.Ams[x].Temp, 64)
-			ams_temp_1 := prometheus.MustNewConstMetric(collector.amsTempMetric, prometheus.GaugeValue, ams_temp, strconv.Itoa(x))
-			ch <- ams_temp_1
-
-			humidity, _ := strconv.ParseFloat(datav2.Print.Ams.Ams[x].Humidity, 64)
-			humidity_1 := prometheus.MustNewConstMetric(collector.amsHumidityMetric, prometheus.GaugeValue, humidity, strconv.Itoa(x))
-			ch <- humidity_1
-
-			// loop through the Trays
-			for i := 0; i < len(datav2.Print.Ams.Ams[x].Tray); i++ {
-
-				ams_bed_temp, _ := strconv.ParseFloat(datav2.Print.Ams.Ams[x].Tray[i].BedTemp, 64)
-				ams_bed_temp_1 := prometheus.MustNewConstMetric(collector.amsBedTempMetric, prometheus.GaugeValue, ams_bed_temp, strconv.Itoa(x), strconv.Itoa(i))
-				ch <- ams_bed_temp_1
-
-				ams_tray_color := datav2.Print.Ams.Ams[x].Tray[i].TrayColor
-				ams_tray_type := datav2.Print.Ams.Ams[x].Tray[i].TrayType
-				//ams_tray_id, _ := strconv.ParseFloat(datav2.Print.Ams.Ams[x].Tray[i].ID, 64)
-				ams_color_1 := prometheus.MustNewConstMetric(collector.amsColorMetric, prometheus.GaugeValue, 1, strconv.Itoa(x), strconv.Itoa(i), ams_tray_color, ams_tray_type)
-				ch <- ams_color_1
-
-			}
+	for _, p := range collector.printers {
+		// Take one snapshot per scrape so every metric of a printer comes from
+		// the same report.
+		state := p.state.snapshot()
+
+		collector.collectConnection(ch, p)
+		if collector.collectFreshness(ch, p, state) || collector.keepStaleSeries {
+			collector.collectPrinter(ch, p, state)
 		}
+	}
+	collector.histograms.collect(ch)
+}
 
-		//fmt.Println("\nHumidity: ", ams_temp)
-		// humidity_1 := prometheus.MustNewConstMetric(collector.amsHumidityMetric, prometheus.GaugeValue, humidity)
-		// ch <- humidity_1
-
-		// ams_temp_1 := prometheus.MustNewConstMetric(collector.amsTempMetric, prometheus.GaugeValue, ams_temp)
-		// ch <- ams_temp_1
-
-		// ams_bed_temp_1 := prometheus.MustNewConstMetric(collector.amsBedTempMetric, prometheus.GaugeValue, ams_bed_temp)
-		// ch <- ams_bed_temp_1
-
-		layer_number_1 := prometheus.MustNewConstMetric(collector.layerNumberMetric, prometheus.GaugeValue, layer_number)
-		ch <- layer_number_1
-
-		print_error_1 := prometheus.MustNewConstMetric(collector.printErrorMetric, prometheus.GaugeValue, print_error)
-		ch <- print_error_1
-
-		wifi_signal_1 := prometheus.MustNewConstMetric(collector.wifiSignalMetric, prometheus.GaugeValue, wifi_signal)
-		ch <- wifi_signal_1
-
-		big_fan1_speed_1 := prometheus.MustNewConstMetric(collector.bigFan1SpeedMetric, prometheus.GaugeValue, big_fan1_speed)
-		ch <- big_fan1_speed_1
-
-		big_fan2_speed_1 := prometheus.MustNewConstMetric(collector.bigFan2SpeedMetric, prometheus.GaugeValue, big_fan2_speed)
-		ch <- big_fan2_speed_1
-
-		chamber_temper_1 := prometheus.MustNewConstMetric(collector.chamberTemperMetric, prometheus.GaugeValue, chamber_temper)
-		ch <- chamber_temper_1
-
-		cooling_fan_speed_1 := prometheus.MustNewConstMetric(collector.coolingFanSpeedMetric, prometheus.GaugeValue, cooling_fan_speed)
-		ch <- cooling_fan_speed_1
-
-		fail_reason_metric_1 := prometheus.MustNewConstMetric(collector.failReasonMetric, prometheus.GaugeValue, fail_reason)
-		ch <- fail_reason_metric_1
-
-		fan_gear_metric_1 := prometheus.MustNewConstMetric(collector.fanGearMetric, prometheus.GaugeValue, fan_gear)
-		ch <- fan_gear_metric_1
-
-		mc_percent_1 := prometheus.MustNewConstMetric(collector.mcPercentMetric, prometheus.GaugeValue, mc_percent)
-		ch <- mc_percent_1
-
-		mc_print_error_code_1 := prometheus.MustNewConstMetric(collector.mcPrintErrorCodeMetric, prometheus.GaugeValue, mc_print_error_code)
-		ch <- mc_print_error_code_1
-
-		mc_print_stage_metric_1 := prometheus.MustNewConstMetric(collector.mcPrintStageMetric, prometheus.GaugeValue, mc_print_stage)
-		ch <- mc_print_stage_metric_1
+// collectFreshness reports when the printer was last heard from and whether
+// that is recent enough to trust its metrics.
+func (collector *bambulabsCollector) collectFreshness(ch chan<- prometheus.Metric, p *printer, state *printerState) bool {
+	labels := []string{p.config.Name, p.config.Serial}
+	lastMessage := state.LastMessage
 
-		mc_print_sub_stage_metric_1 := prometheus.MustNewConstMetric(collector.mcPrintSubStageMetric, prometheus.GaugeValue, mc_print_sub_stage)
-		ch <- mc_print_sub_stage_metric_1
+	up := !lastMessage.IsZero() && time.Since(lastMessage) <= collector.maxReportAge
+	upValue := 0.0
+	if up {
+		upValue = 1
+	}
 
-		mc_remaining_time_metric_1 := prometheus.MustNewConstMetric(collector.mcRemainingTimeMetric, prometheus.GaugeValue, mc_remaining_time)
-		ch <- mc_remaining_time_metric_1
+	ch <- prometheus.MustNewConstMetric(collector.printerUpMetric, prometheus.GaugeValue, upValue, labels...)
+	if !lastMessage.IsZero() {
+		ch <- prometheus.MustNewConstMetric(collector.lastMessageMetric, prometheus.GaugeValue, float64(lastMessage.Unix()), labels...)
+	}
+	return up
+}
 
-		nozzle_target_temper_metric_1 := prometheus.MustNewConstMetric(collector.nozzleTargetTemperMetric, prometheus.GaugeValue, nozzle_target_temper)
-		ch <- nozzle_target_temper_metric_1
+func (collector *bambulabsCollector) collectConnection(ch chan<- prometheus.Metric, p *printer) {
+	labels := []string{p.config.Name, p.config.Serial}
 
-		nozzle_temper_metric_1 := prometheus.MustNewConstMetric(collector.nozzleTemperMetric, prometheus.GaugeValue, nozzle_temper)
-		ch <- nozzle_temper_metric_1
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
-		client.Disconnect(1)
-		token.Done()
-	} else {
-		fmt.Printf("\ndata might be empty")
+	connected := 0.0
+	if p.connected {
+		connected = 1
+	}
+	reconnects := 0
+	if p.connects > 1 {
+		reconnects = p.connects - 1
 	}
 
+	ch <- prometheus.MustNewConstMetric(collector.mqttConnectedMetric, prometheus.GaugeValue, connected, labels...)
+	ch <- prometheus.MustNewConstMetric(collector.mqttReconnectsMetric, prometheus.CounterValue, float64(reconnects), labels...)
+	if !p.lastConnect.IsZero() {
+		ch <- prometheus.MustNewConstMetric(collector.mqttLastConnectMetric, prometheus.GaugeValue, float64(p.lastConnect.Unix()), labels...)
+	}
+	ch <- prometheus.MustNewConstMetric(collector.mqttIDCollisionsMetric, prometheus.CounterValue, float64(p.idCollisions), labels...)
+	for reason, count := range p.connectionErrors {
+		ch <- prometheus.MustNewConstMetric(collector.mqttConnectionErrorsMetric, prometheus.CounterValue, float64(count), append(labels, reason)...)
+	}
 }
 
-var messagePubHandler mqtt.MessageHandler = func(client mqtt.Client, msg mqtt.Message) {
-	//fmt.Printf("Payload %s\n", msg.Payload())
-	s := msg.Payload()
-	data := BambuLabsX1C{}
-	json.Unmarshal([]byte(s), &data)
-
-	//if reflect.ValueOf(data).IsZero() == false {
-	//fmt.Printf("\nHumidity: %s", data.Print.Ams.Ams[0].Humidity)
-	if data.Print.WifiSignal == "" {
-		//fmt.Println("\nWifi Signal was empty")
-	} else {
-		datav2 = data
-
-		//humidity, _ = strconv.ParseFloat(data.Print.Ams.Ams[0].Humidity, 64)
-		//ams_temp, _ = strconv.ParseFloat(data.Print.Ams.Ams[0].Temp, 64)
-		//ams_bed_temp, _ = strconv.ParseFloat(data.Print.Ams.Ams[0].Tray[0].BedTemp, 64)
-		layer_number = float64(data.Print.LayerNum)
-		print_error = float64(data.Print.PrintError)
-		wifi_signal, _ = strconv.ParseFloat(strings.ReplaceAll(data.Print.WifiSignal, "dBm", ""), 64)
-		big_fan1_speed, _ = strconv.ParseFloat(data.Print.BigFan1Speed, 64)
-		big_fan2_speed, _ = strconv.ParseFloat(data.Print.BigFan2Speed, 64)
-		chamber_temper = data.Print.ChamberTemper
-		cooling_fan_speed, _ = strconv.ParseFloat(data.Print.CoolingFanSpeed, 64)
-		fail_reason, _ = strconv.ParseFloat(data.Print.FailReason, 64)
-		fan_gear = float64(data.Print.FanGear)
-		mc_percent = float64(data.Print.McPercent)
-		mc_print_error_code, _ = strconv.ParseFloat(data.Print.McPrintErrorCode, 64)
-		mc_print_stage, _ = strconv.ParseFloat(data.Print.McPrintStage, 64)
-		mc_print_sub_stage = float64(data.Print.McPrintSubStage)
-		mc_remaining_time = float64(data.Print.McRemainingTime)
-		nozzle_temper = float64(data.Print.NozzleTemper)
+func (collector *bambulabsCollector) collectPrinter(ch chan<- prometheus.Metric, p *printer, state *printerState) {
+	snapshot := state.Data
+	labels := []string{p.config.Name, p.config.Serial}
 
+	collector.collectAMS(ch, labels, snapshot)
+	collector.collectAMSBits(ch, labels, snapshot)
+	collector.collectTrays(ch, labels, state)
+
+	for _, metric := range collector.fieldMetrics {
+		value, ok := metric.value(snapshot)
+		if !ok {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(metric.desc, prometheus.GaugeValue, value, labels...)
 	}
-}
 
-var connectHandler mqtt.OnConnectHandler = func(client mqtt.Client) {
-	dt := time.Now()
-	fmt.Println("\nConnected: ", dt.String())
+	collector.collectGcodeState(ch, labels, snapshot)
+	collector.collectHMS(ch, labels, snapshot)
+	collector.collectJob(ch, labels, snapshot)
+	collector.collectFirmware(ch, labels, state)
+	collector.collectUpload(ch, labels, snapshot)
+	collector.collectXcam(ch, labels, snapshot)
+	collector.collectLights(ch, labels, snapshot)
+	collector.collectCamera(ch, labels, snapshot)
+	collector.collectSpeedLevel(ch, labels, snapshot)
+	collector.collectStage(ch, labels, snapshot)
+	collector.collectLayerProgress(ch, labels, snapshot)
+	collector.collectFans(ch, labels, snapshot)
 }
 
-var connectLostHandler mqtt.ConnectionLostHandler = func(client mqtt.Client, err error) {
-	fmt.Printf("\nConnect lost: %+v", err)
+// printerLabelNames prefixes the labels every printer metric carries.
+func printerLabelNames(labels ...string) []string {
+	return append([]string{"printer", "serial"}, labels...)
 }
 
 func main() {
 	dt := time.Now()
-	fmt.Printf("\nStarting Exporter: ", dt.String())
+	fmt.Printf("\nStarting Exporter: %s", dt.String())
 	godotenv.Load()
 
-	broker = env("BAMBU_PRINTER_IP")
-	username = env("USERNAME")
-	password = env("PASSWORD")
-	mqtt_topic = env("MQTT_TOPIC")
-
-	if broker == "" {
-		broker = os.Getenv("BAMBU_PRINTER_IP")
+	config, err := loadConfig()
+	if err != nil {
+		log.Fatalf("Error loading config: %v", err)
 	}
 
-	if password == "" {
-		password = os.Getenv("PASSWORD")
-	}
+	fmt.Printf("\nConfig Loaded")
 
-	if mqtt_topic == "device/<>/report" {
-		mqtt_topic = os.Getenv("MQTT_TOPIC")
+	histograms := newJobHistograms()
+	var printers []*printer
+	for _, printerConfig := range config.Printers {
+		fmt.Printf("\nConnecting to printer %s (%s)", printerConfig.Name, printerConfig.Address)
+		p := newPrinter(printerConfig, histograms)
+		if err := p.connect(); err != nil {
+			log.Fatalf("Error connecting to %s: %v", printerConfig.Name, err)
+		}
+		printers = append(printers, p)
 	}
 
-	fmt.Printf("\nEnv Vars Loaded")
-
 	fmt.Printf("\nRegistering collector")
-	bambulabs := newBambulabsCollector()
+	bambulabs := newBambulabsCollector(printers, histograms, config)
 	prometheus.MustRegister(bambulabs)
 	http.HandleFunc("/", home)
 	http.HandleFunc("/healthz", healthz)
 	http.Handle("/metrics", promhttp.Handler())
-	log.Fatal(http.ListenAndServe(":9101", nil))
+
+	server := &http.Server{Addr: ":9101"}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	// Wait for a stop signal so the printers get a clean MQTT disconnect
+	// instead of holding their session open until it times out.
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+
+	fmt.Printf("\nShutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(ctx)
+	for _, p := range printers {
+		p.disconnect()
+	}
 }
 
 const body = `<html>
@@ -423,18 +598,8 @@ func healthz(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "OK")
 }
 
-func newTLSConfig() *tls.Config {
-	return &tls.Config{InsecureSkipVerify: true}
-}
-
-func sub(client mqtt.Client) {
-	// Subscribe to the LWT connection status
-	topic := mqtt_topic
-	token := client.Subscribe(topic, 1, nil)
-	token.Wait()
-	fmt.Printf("Subscribed to LWT %s", topic)
-}
-
+// BambuLabsX1C is the report a printer pushes on device/<serial>/report. The
+// metric and help tags on Print fields drive newFieldMetrics.
 type BambuLabsX1C struct {
 	Print struct {
 		Ams struct {
@@ -474,67 +639,70 @@ type BambuLabsX1C struct {
 			TrayTar          string `json:"tray_tar"`
 			Version          int    `json:"version"`
 		} `json:"ams"`
-		AmsRfidStatus           int     `json:"ams_rfid_status"`
-		AmsStatus               int     `json:"ams_status"`
-		BedTargetTemper         float64 `json:"bed_target_temper"`
-		BedTemper               float64 `json:"bed_temper"`
-		BigFan1Speed            string  `json:"big_fan1_speed"`
-		BigFan2Speed            string  `json:"big_fan2_speed"`
-		ChamberTemper           float64 `json:"chamber_temper"`
+		AmsRfidStatus           int     `json:"ams_rfid_status" help:"AMS RFID reader status"`
+		AmsStatus               int     `json:"ams_status" help:"AMS status"`
+		BedTargetTemper         float64 `json:"bed_target_temper" help:"Bed Target Temperature Metric"`
+		BedTemper               float64 `json:"bed_temper" help:"Bed Temperature Metric"`
+		BigFan1Speed            string  `json:"big_fan1_speed" metric:"big_fan1_speed_metric" help:"Big Fan 1 Speed"`
+		BigFan2Speed            string  `json:"big_fan2_speed" metric:"big_fan2_speed_metric" help:"Big Fan 2 Speed"`
+		ChamberTemper           float64 `json:"chamber_temper" help:"Chamber Temperature of Printer"`
 		Command                 string  `json:"command"`
-		CoolingFanSpeed         string  `json:"cooling_fan_speed"`
-		FailReason              string  `json:"fail_reason"`
-		FanGear                 int     `json:"fan_gear"`
+		CoolingFanSpeed         string  `json:"cooling_fan_speed" metric:"cooling_fan_speed_metric" help:"Cooling Fan Speed"`
+		FailReason              string  `json:"fail_reason" metric:"fail_reason_metric" help:"Print Failure Reason"`
+		FanGear                 int     `json:"fan_gear" help:"Fan Gear"`
 		ForceUpgrade            bool    `json:"force_upgrade"`
 		GcodeFile               string  `json:"gcode_file"`
-		GcodeFilePreparePercent string  `json:"gcode_file_prepare_percent"`
+		GcodeFilePreparePercent string  `json:"gcode_file_prepare_percent" metric:"gcode_file_prepare_percent_metric" help:"Percentage of the gcode file prepared for printing"`
 		GcodeStartTime          string  `json:"gcode_start_time"`
 		GcodeState              string  `json:"gcode_state"`
-		HeatbreakFanSpeed       string  `json:"heatbreak_fan_speed"`
-		Hms                     []any   `json:"hms"`
-		HomeFlag                int     `json:"home_flag"`
-		HwSwitchState           int     `json:"hw_switch_state"`
-		Ipcam                   struct {
+		HeatbreakFanSpeed       string  `json:"heatbreak_fan_speed" metric:"heatbreak_fan_speed_metric" help:"Heatbreak Fan Speed"`
+		Hms                     []struct {
+			Attr int `json:"attr"`
+			Code int `json:"code"`
+		} `json:"hms"`
+		HomeFlag      int `json:"home_flag" help:"Home flag bitfield"`
+		HwSwitchState int `json:"hw_switch_state" help:"Hardware switch state"`
+		Ipcam         struct {
 			IpcamDev    string `json:"ipcam_dev"`
 			IpcamRecord string `json:"ipcam_record"`
 			Resolution  string `json:"resolution"`
 			Timelapse   string `json:"timelapse"`
 		} `json:"ipcam"`
-		LayerNum     int    `json:"layer_num"`
+		LayerNum     int    `json:"layer_num" metric:"layer_number_metric" help:"layer number of the print head in gcode"`
 		Lifecycle    string `json:"lifecycle"`
 		LightsReport []struct {
 			Mode string `json:"mode"`
 			Node string `json:"node"`
 		} `json:"lights_report"`
-		Maintain            int     `json:"maintain"`
-		McPercent           int     `json:"mc_percent"`
-		McPrintErrorCode    string  `json:"mc_print_error_code"`
-		McPrintStage        string  `json:"mc_print_stage"`
-		McPrintSubStage     int     `json:"mc_print_sub_stage"`
-		McRemainingTime     int     `json:"mc_remaining_time"`
+		Maintain            int     `json:"maintain" help:"Maintenance flags"`
+		McPercent           int     `json:"mc_percent" help:"Percentage of Progress of print"`
+		McPrintErrorCode    string  `json:"mc_print_error_code" metric:"mc_print_error_code_metric" help:"Print Progress Error Code"`
+		McPrintStage        string  `json:"mc_print_stage" metric:"mc_print_stage_metric" help:"Print Progress Stage"`
+		McPrintSubStage     int     `json:"mc_print_sub_stage" help:"Print Progress Sub Stage"`
+		McRemainingTime     int     `json:"mc_remaining_time" help:"Print Progress Remaining Time in minutes"`
 		MessProductionState string  `json:"mess_production_state"`
-		NozzleTargetTemper  float64 `json:"nozzle_target_temper"`
-		NozzleTemper        float64 `json:"nozzle_temper"`
+		NozzleTargetTemper  float64 `json:"nozzle_target_temper" help:"Nozzle Target Temperature Metric"`
+		NozzleTemper        float64 `json:"nozzle_temper" help:"Nozzle Temperature Metric"`
 		Online              struct {
 			Ahb  bool `json:"ahb"`
 			Rfid bool `json:"rfid"`
 		} `json:"online"`
-		PrintError       int    `json:"print_error"`
-		PrintGcodeAction int    `json:"print_gcode_action"`
-		PrintRealAction  int    `json:"print_real_action"`
+		PrintError       int    `json:"print_error" help:"Print error int"`
+		PrintGcodeAction int    `json:"print_gcode_action" help:"Print gcode action"`
+		PrintRealAction  int    `json:"print_real_action" help:"Print real action"`
 		PrintType        string `json:"print_type"`
 		ProfileID        string `json:"profile_id"`
 		ProjectID        string `json:"project_id"`
 		Sdcard           bool   `json:"sdcard"`
 		SequenceID       string `json:"sequence_id"`
-		SpdLvl           int    `json:"spd_lvl"`
-		SpdMag           int    `json:"spd_mag"`
+		SpdLvl           int    `json:"spd_lvl" help:"Print speed level"`
+		SpdMag           int    `json:"spd_mag" metric:"bambulab_print_speed_magnitude_percent" help:"Print speed magnitude in percent"`
 		Stg              []int  `json:"stg"`
-		StgCur           int    `json:"stg_cur"`
+		StgCur           *int   `json:"stg_cur" help:"Current print stage"`
 		SubtaskID        string `json:"subtask_id"`
 		SubtaskName      string `json:"subtask_name"`
 		TaskID           string `json:"task_id"`
-		TotalLayerNum    int    `json:"total_layer_num"`
+		TotalLayerNum    int    `json:"total_layer_num" metric:"bambulab_total_layers" help:"Total number of layers of the print"`
 		UpgradeState     struct {
 			AhbNewVersionNumber string `json:"ahb_new_version_number"`
 			AmsNewVersionNumber string `json:"ams_new_version_number"`
@@ -563,8 +731,8 @@ type BambuLabsX1C struct {
 			TimeRemaining int    `json:"time_remaining"`
 			TroubleID     string `json:"trouble_id"`
 		} `json:"upload"`
-		WifiSignal string `json:"wifi_signal"`
-		Xcam       struct {
+		WifiSignal string `json:"wifi_signal" metric:"wifi_signal_metric" help:"Wifi signal in dBm"`
+		Xcam       *struct {
 			AllowSkipParts           bool   `json:"allow_skip_parts"`
 			BuildplateMarkerDetector bool   `json:"buildplate_marker_detector"`
 			FirstLayerInspector      bool   `json:"first_layer_inspector"`
@@ -573,6 +741,6 @@ type BambuLabsX1C struct {
 			PrintingMonitor          bool   `json:"printing_monitor"`
 			SpaghettiDetector        bool   `json:"spaghetti_detector"`
 		} `json:"xcam"`
-		XcamStatus string `json:"xcam_status"`
+		XcamStatus string `json:"xcam_status" metric:"bambulab_xcam_status" help:"Status of the camera based failure detection"`
 	} `json:"print"`
 }
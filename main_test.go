@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TestCollectWhileApplying scrapes while reports arrive, so go test -race can
+// catch scrapes reading state the MQTT handler is writing.
+func TestCollectWhileApplying(t *testing.T) {
+	histograms := newJobHistograms()
+	p := newPrinter(printerConfig{Name: "test", Serial: "00M00A000000000"}, histograms)
+	collector := newBambulabsCollector([]*printer{p}, histograms, exporterConfig{MaxReportAge: duration(time.Hour)})
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			report := fmt.Sprintf(`{"print": {"command": "push_status", "msg": %d, "nozzle_temper": %d, "stg_cur": %d, "layer_num": %d, "total_layer_num": 200,
+				"task_id": "1", "gcode_start_time": "100", "hms": [{"attr": 50331904, "code": 131073}],
+				"ams": {"ams_exist_bits": "1", "tray_exist_bits": "3", "tray_now": "%d", "ams": [{"id": "0", "humidity": "4", "tray": [{"id": "0", "tray_type": "PLA"}, {"id": "1", "tray_type": "PETG"}]}]}}}`,
+				i%2, 200+i%20, i%3, i, i%2)
+			update, err := decodeReport([]byte(report))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			p.state.apply(update, time.Now())
+		}
+	}()
+
+	for i := 0; i < 50; i++ {
+		if _, err := registry.Gather(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+}
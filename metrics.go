@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fieldMetric exports one top level field of the print report as a gauge.
+type fieldMetric struct {
+	index []int
+	desc  *prometheus.Desc
+}
+
+// newFieldMetrics builds a gauge for every numeric field of
+// BambuLabsX1C.Print. The name is taken from the field's metric tag, or
+// <json name>_metric without one, and the help text from its help tag.
+// String fields are only exported when they carry a metric tag, since most
+// of them are not numbers. Pointer fields are exported when the report set
+// them. A metric tag of "-" skips the field.
+//
+// Nested blocks such as the AMS are exported separately because they need
+// their own labels.
+func newFieldMetrics() []fieldMetric {
+	var metrics []fieldMetric
+
+	printType := reflect.TypeOf(BambuLabsX1C{}.Print)
+	for i := 0; i < printType.NumField(); i++ {
+		field := printType.Field(i)
+		name, tagged := field.Tag.Lookup("metric")
+		if name == "-" {
+			continue
+		}
+
+		kind := field.Type.Kind()
+		if kind == reflect.Ptr {
+			kind = field.Type.Elem().Kind()
+		}
+		switch kind {
+		case reflect.Int, reflect.Float64:
+		case reflect.String:
+			if !tagged {
+				continue
+			}
+		default:
+			if tagged {
+				panic(fmt.Sprintf("metric tag on %s, which is not a number", field.Name))
+			}
+			continue
+		}
+
+		jsonName := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = jsonName + "_metric"
+		}
+		help := field.Tag.Get("help")
+		if help == "" {
+			help = fmt.Sprintf("Value of %s in the print report", jsonName)
+		}
+
+		metrics = append(metrics, fieldMetric{
+			index: field.Index,
+			desc:  prometheus.NewDesc(name, help, printerLabelNames(), nil),
+		})
+	}
+
+	return metrics
+}
+
+// value reads the field from a report. String fields that don't hold a
+// number read as 0, pointer fields the report didn't set are not ok.
+func (metric fieldMetric) value(report BambuLabsX1C) (float64, bool) {
+	field := reflect.ValueOf(report.Print).FieldByIndex(metric.index)
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return 0, false
+		}
+		field = field.Elem()
+	}
+	switch field.Kind() {
+	case reflect.Int:
+		return float64(field.Int()), true
+	case reflect.Float64:
+		return field.Float(), true
+	case reflect.String:
+		return parseFloat(field.String()), true
+	}
+	return 0, true
+}
+
+// parseFloat reads a number the printer sent as a string, ignoring a unit
+// suffix such as the one on "-45dBm".
+func parseFloat(s string) float64 {
+	f, _ := strconv.ParseFloat(strings.TrimRightFunc(s, unicode.IsLetter), 64)
+	return f
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 2 * time.Minute
+
+	// stableConnection is how long a connection has to stay up before the
+	// backoff starts over. Connections the printer accepts and then drops
+	// right away, such as two clients taking over the same ID, keep backing
+	// off instead.
+	stableConnection = 30 * time.Second
+)
+
+// printer holds the MQTT connection and last known report of one printer.
+type printer struct {
+	config printerConfig
+	client mqtt.Client
+	state  *stateStore
+
+	sequenceID uint64
+	lost       chan struct{}
+	done       chan struct{}
+
+	// Connection statistics, written from the paho callbacks.
+	mu               sync.Mutex
+	connected        bool
+	connects         int
+	lastConnect      time.Time
+	connectionErrors map[string]int
+	idCollisions     int
+}
+
+func newPrinter(config printerConfig, histograms *jobHistograms) *printer {
+	return &printer{
+		config:           config,
+		state:            newStateStore(histograms.forPrinter(config)),
+		lost:             make(chan struct{}, 1),
+		done:             make(chan struct{}),
+		connectionErrors: map[string]int{},
+	}
+}
+
+func (p *printer) reportTopic() string {
+	return fmt.Sprintf("device/%s/report", p.config.Serial)
+}
+
+func (p *printer) requestTopic() string {
+	return fmt.Sprintf("device/%s/request", p.config.Serial)
+}
+
+// connect opens the long-lived connection to the printer. Reports are
+// handled by messageHandler as they arrive, so scrapes only have to read
+// the last known state.
+func (p *printer) connect() error {
+	tlsConfig, err := newTLSConfig(p.config)
+	if err != nil {
+		return err
+	}
+
+	opts := mqtt.NewClientOptions()
+	opts.AddBroker(fmt.Sprintf("ssl://%s:%d", p.config.Address, p.config.Port))
+	opts.SetClientID(p.config.ClientID)
+	opts.SetUsername(p.config.Username)
+	opts.SetPassword(p.config.AccessCode)
+	opts.SetDefaultPublishHandler(p.messageHandler)
+	// Reconnecting is handled by run so the delay between attempts can back
+	// off with jitter.
+	opts.SetAutoReconnect(false)
+	opts.OnConnect = p.connectHandler
+	opts.OnConnectionLost = p.connectLostHandler
+
+	opts.SetTLSConfig(tlsConfig)
+	p.client = mqtt.NewClient(opts)
+	go p.run()
+
+	if interval := time.Duration(*p.config.PushallInterval); interval > 0 {
+		go p.pushAllLoop(interval)
+	}
+
+	return nil
+}
+
+func (p *printer) disconnect() {
+	close(p.done)
+	if p.client != nil {
+		p.client.Disconnect(250)
+	}
+}
+
+// run keeps the printer connected. Failed attempts are retried with an
+// exponential backoff. A lost connection is re-established after a short
+// delay if it had been stable, otherwise the backoff keeps growing.
+func (p *printer) run() {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	delay := minReconnectDelay
+	for {
+		token := p.client.Connect()
+		token.Wait()
+		if err := token.Error(); err != nil {
+			reason := connectionErrorReason(err)
+			p.recordConnectionError(reason)
+			fmt.Printf("\n%s: Connect failed (%s): %v", p.config.Name, reason, err)
+			if reason == "client_id_rejected" {
+				p.recordIDCollision("rejected")
+			}
+		} else {
+			connected := time.Now()
+			select {
+			case <-p.lost:
+			case <-p.done:
+				return
+			}
+			// A broker that sees a second client with our ID accepts it and
+			// drops us, so a session lost right after connecting is most
+			// likely a collision. Reboots close the socket at any time.
+			if time.Since(connected) >= stableConnection {
+				delay = minReconnectDelay
+			} else {
+				p.recordIDCollision("dropped right after connecting")
+			}
+		}
+
+		select {
+		case <-time.After(withJitter(rng, delay)):
+		case <-p.done:
+			return
+		}
+		delay *= 2
+		if delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// withJitter spreads retries between half and all of the delay so several
+// exporters don't reconnect to a rebooted printer in lockstep.
+func withJitter(rng *rand.Rand, delay time.Duration) time.Duration {
+	half := int64(delay / 2)
+	return time.Duration(half + rng.Int63n(half+1))
+}
+
+// connectionErrorReason maps a connect error to a short label value.
+func connectionErrorReason(err error) string {
+	switch {
+	case errors.Is(err, packets.ErrorRefusedBadUsernameOrPassword), errors.Is(err, packets.ErrorRefusedNotAuthorised):
+		return "auth"
+	case errors.Is(err, packets.ErrorRefusedIDRejected):
+		return "client_id_rejected"
+	case errors.Is(err, packets.ErrorRefusedServerUnavailable):
+		return "server_unavailable"
+	}
+
+	// Network errors are flattened to text by paho.
+	message := err.Error()
+	switch {
+	case strings.Contains(message, "certificate"), strings.Contains(message, "x509"), strings.Contains(message, "tls"):
+		return "tls"
+	case strings.Contains(message, "timeout"):
+		return "timeout"
+	case strings.Contains(message, "connection refused"):
+		return "refused"
+	case strings.Contains(message, "network Error"):
+		return "network"
+	}
+	return "other"
+}
+
+// recordIDCollision notes that the broker rejected our client ID or dropped
+// a session that had only just been accepted, which is how a broker treats
+// two clients with the same ID.
+func (p *printer) recordIDCollision(what string) {
+	fmt.Printf("\n%s: Session %s, another client may be using client ID %q. Set client_id (CLIENT_ID) to a unique value.", p.config.Name, what, p.config.ClientID)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.idCollisions++
+}
+
+func (p *printer) recordConnectionError(reason string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.connectionErrors[reason]++
+}
+
+// publish sends a command to the printer's request topic.
+func (p *printer) publish(command map[string]map[string]any) {
+	for _, body := range command {
+		body["sequence_id"] = strconv.FormatUint(atomic.AddUint64(&p.sequenceID, 1), 10)
+	}
+	payload, err := json.Marshal(command)
+	if err != nil {
+		fmt.Printf("\n%s: could not encode command: %v", p.config.Name, err)
+		return
+	}
+	token := p.client.Publish(p.requestTopic(), 1, false, payload)
+	token.Wait()
+	if token.Error() != nil {
+		fmt.Printf("\n%s: publish failed: %v", p.config.Name, token.Error())
+	}
+}
+
+// pushAll asks the printer to send a full report. P1 printers only send
+// what changed unless asked.
+func (p *printer) pushAll() {
+	p.publish(map[string]map[string]any{
+		"pushing": {"command": "pushall", "version": 1, "push_target": 1},
+	})
+}
+
+func (p *printer) pushAllLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if p.client.IsConnectionOpen() {
+				p.pushAll()
+				p.getVersion()
+			}
+		case <-p.done:
+			return
+		}
+	}
+}
+
+func (p *printer) messageHandler(client mqtt.Client, msg mqtt.Message) {
+	//fmt.Printf("Payload %s\n", msg.Payload())
+	if modules, ok := decodeVersion(msg.Payload()); ok {
+		p.state.applyVersion(modules)
+		return
+	}
+
+	update, err := decodeReport(msg.Payload())
+	if err != nil {
+		fmt.Printf("\n%s: could not decode message: %v", p.config.Name, err)
+		return
+	}
+	if update == nil {
+		return
+	}
+
+	// P1 printers, and X1 printers between full reports, only send the
+	// fields that changed.
+	p.state.apply(update, time.Now())
+}
+
+func (p *printer) connectHandler(client mqtt.Client) {
+	dt := time.Now()
+	fmt.Printf("\n%s: Connected: %s", p.config.Name, dt.String())
+
+	p.mu.Lock()
+	p.connected = true
+	p.connects++
+	p.lastConnect = dt
+	p.mu.Unlock()
+
+	// The session is clean, so subscriptions have to be made again after
+	// every reconnect.
+	p.sub(client)
+	p.pushAll()
+	p.getVersion()
+}
+
+func (p *printer) connectLostHandler(client mqtt.Client, err error) {
+	fmt.Printf("\n%s: Connect lost: %+v", p.config.Name, err)
+
+	p.mu.Lock()
+	p.connected = false
+	p.connectionErrors["connection_lost"]++
+	p.mu.Unlock()
+
+	select {
+	case p.lost <- struct{}{}:
+	default:
+	}
+}
+
+func (p *printer) sub(client mqtt.Client) {
+	topic := p.reportTopic()
+	token := client.Subscribe(topic, 1, nil)
+	token.Wait()
+	fmt.Printf("\n%s: Subscribed to %s", p.config.Name, topic)
+}
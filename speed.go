@@ -0,0 +1,25 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// speedLevels names the spd_lvl values 1 to 4.
+var speedLevels = []string{"silent", "standard", "sport", "ludicrous"}
+
+// collectSpeedLevel exports the speed profile the printer is running as a
+// state set.
+func (collector *bambulabsCollector) collectSpeedLevel(ch chan<- prometheus.Metric, labels []string, report BambuLabsX1C) {
+	level := report.Print.SpdLvl
+	if level == 0 {
+		return
+	}
+
+	current := strconv.Itoa(level)
+	if level >= 1 && level <= len(speedLevels) {
+		current = speedLevels[level-1]
+	}
+	collectStateSet(ch, collector.speedLevelMetric, labels, speedLevels, current)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// stageNames names the stg and stg_cur codes, in the order Bambu Studio
+// lists them.
+var stageNames = []string{
+	"printing",
+	"auto_bed_leveling",
+	"heatbed_preheating",
+	"vibration_compensation",
+	"changing_filament",
+	"m400_pause",
+	"paused_filament_runout",
+	"heating_hotend",
+	"calibrating_extrusion",
+	"scanning_bed_surface",
+	"inspecting_first_layer",
+	"identifying_build_plate",
+	"calibrating_micro_lidar",
+	"homing_toolhead",
+	"cleaning_nozzle_tip",
+	"checking_extruder_temperature",
+	"paused_user",
+	"paused_front_cover_falling",
+	"calibrating_lidar",
+	"calibrating_extrusion_flow",
+	"paused_nozzle_temperature_malfunction",
+	"paused_heatbed_temperature_malfunction",
+	"unloading_filament",
+	"paused_skipped_step",
+	"loading_filament",
+	"calibrating_motor_noise",
+	"paused_ams_lost",
+	"paused_heatbreak_fan_low_speed",
+	"paused_chamber_temperature_control_error",
+	"cooling_chamber",
+	"paused_user_gcode",
+	"motor_noise_showoff",
+	"paused_nozzle_filament_covered",
+	"paused_cutter_error",
+	"paused_first_layer_error",
+	"paused_nozzle_clog",
+}
+
+// stageIdle is the name of stg_cur 255, and of -1 that some firmware sends
+// instead.
+const stageIdle = "idle"
+
+// stageStates are the states of the bambulab_print_stage state set.
+var stageStates = append(append([]string{}, stageNames...), stageIdle)
+
+// stageName returns the name of a stage code, or the code itself when it is
+// not in the table.
+func stageName(code int) string {
+	switch {
+	case code == -1 || code == 255:
+		return stageIdle
+	case code >= 0 && code < len(stageNames):
+		return stageNames[code]
+	}
+	return strconv.Itoa(code)
+}
+
+// collectStage exports the current stage as a state set and the stages the
+// printer planned for the job.
+func (collector *bambulabsCollector) collectStage(ch chan<- prometheus.Metric, labels []string, report BambuLabsX1C) {
+	// Without stg_cur the printer hasn't said which stage it is in. Reading it
+	// as 0 would report printing.
+	if report.Print.StgCur != nil {
+		collectStateSet(ch, collector.printStageMetric, labels, stageStates, stageName(*report.Print.StgCur))
+	}
+
+	planned := map[string]bool{}
+	for _, code := range report.Print.Stg {
+		name := stageName(code)
+		if planned[name] {
+			continue
+		}
+		planned[name] = true
+		ch <- prometheus.MustNewConstMetric(collector.printStagePlannedMetric, prometheus.GaugeValue, 1, append(labels, name)...)
+	}
+}
+
+// observeStage records how long the previous stage took once the printer has
+// moved on from it. Stages that were already running when the exporter
+// started have no known start and are left out, as is idle time.
+func (observers jobObservers) observeStage(prev, job jobState) {
+	if prev.StageStart.IsZero() || job.StageStart.Equal(prev.StageStart) {
+		return
+	}
+	name := stageName(prev.Stage)
+	if name == stageIdle {
+		return
+	}
+	observers.stageDuration.WithLabelValues(name).Observe(job.StageStart.Sub(prev.StageStart).Seconds())
+}
+
+// stageDurationBuckets go from 5 seconds to a bit over 5 hours.
+var stageDurationBuckets = prometheus.ExponentialBuckets(5, 2.5, 10)
+
+func newStageDurationHistogram() *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "bambulab_print_stage_duration_seconds",
+		Help:    "Time spent in each print stage, one observation each time the printer leaves a stage",
+		Buckets: stageDurationBuckets,
+	}, printerLabelNames("stage"))
+}
+
+// stageTransition moves the job to the stage in the report, noting when it
+// started. The first stage seen keeps a zero start since it may have begun
+// before the exporter connected.
+func (job jobState) stageTransition(report BambuLabsX1C, received time.Time) jobState {
+	if report.Print.StgCur == nil {
+		return job
+	}
+	stage := *report.Print.StgCur
+	if !job.stageKnown {
+		job.Stage = stage
+		job.stageKnown = true
+		return job
+	}
+	if stage != job.Stage {
+		job.Stage = stage
+		job.StageStart = received
+	}
+	return job
+}
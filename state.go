@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// printerState is one consistent view of a printer, built from a single
+// merged report. It is never modified once published, so scrapes can read
+// it without holding a lock.
+type printerState struct {
+	Data        BambuLabsX1C
+	LastMessage time.Time
+	Job         jobState
+
+	// Modules is the last get_version response.
+	Modules []moduleVersion
+}
+
+// stateStore holds the merged report of one printer. The MQTT handler
+// applies updates while scrapes read snapshots from other goroutines.
+type stateStore struct {
+	mu        sync.RWMutex
+	report    map[string]any
+	state     *printerState
+	observers jobObservers
+}
+
+func newStateStore(observers jobObservers) *stateStore {
+	return &stateStore{
+		report:    map[string]any{},
+		state:     &printerState{},
+		observers: observers,
+	}
+}
+
+// apply merges an update into the report and publishes a new state.
+func (s *stateStore) apply(update map[string]any, received time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mergeReport(s.report, update, isFullReport(update))
+	data := reportState(s.report)
+	job := s.state.Job.next(data, received)
+	s.observers.observe(s.state.Job, job)
+	s.state = &printerState{
+		Data:        data,
+		LastMessage: received,
+		Job:         job,
+		Modules:     s.state.Modules,
+	}
+}
+
+// applyVersion publishes a new state with the module versions replaced.
+func (s *stateStore) applyVersion(modules []moduleVersion) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := *s.state
+	state.Modules = modules
+	s.state = &state
+}
+
+// snapshot returns the latest state. All of it comes from the same report.
+func (s *stateStore) snapshot() *printerState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state
+}
+
+// decodeReport parses an MQTT payload and returns its "print" object, or nil
+// when the message is not a push_status report.
+func decodeReport(payload []byte) (map[string]any, error) {
+	var msg struct {
+		Print map[string]any `json:"print"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	// Keep numbers as they were sent so re-encoding the merged state does not
+	// round large integers through float64.
+	decoder.UseNumber()
+	if err := decoder.Decode(&msg); err != nil {
+		return nil, err
+	}
+	if msg.Print == nil || msg.Print["command"] != "push_status" {
+		return nil, nil
+	}
+	return msg.Print, nil
+}
+
+// mergeReport deep-merges a (possibly partial) report into the last known
+// one. Objects are merged key by key, lists of objects that carry an "id"
+// (or "node") are merged element by element, and anything else replaces the old value.
+// Fields missing from the update keep their previous value.
+//
+// In a full report the keyed lists are complete, so elements missing from
+// them, such as the trays of an unplugged AMS unit, are dropped.
+func mergeReport(dst, src map[string]any, full bool) {
+	for key, value := range src {
+		switch value := value.(type) {
+		case map[string]any:
+			if existing, ok := dst[key].(map[string]any); ok {
+				mergeReport(existing, value, full)
+				continue
+			}
+		case []any:
+			if existing, ok := dst[key].([]any); ok {
+				if merged, ok := mergeList(existing, value, full); ok {
+					dst[key] = merged
+					continue
+				}
+			}
+		}
+		dst[key] = src[key]
+	}
+}
+
+// isFullReport reports whether an update is a full report, which the
+// printer marks with "msg": 0. Incremental updates carry "msg": 1.
+func isFullReport(update map[string]any) bool {
+	msg, ok := update["msg"].(json.Number)
+	return ok && msg == "0"
+}
+
+// mergeList merges two lists of objects keyed by their "id" field, such as
+// AMS units and their trays, or by "node" for lights_report. It reports
+// false for any other kind of list.
+//
+// An element that only carries its key, which is how the printer reports an
+// emptied AMS slot, replaces the old one instead of being merged into it.
+func mergeList(dst, src []any, full bool) ([]any, bool) {
+	if len(src) == 0 {
+		return nil, false
+	}
+	for _, item := range src {
+		if _, ok := listID(item); !ok {
+			return nil, false
+		}
+	}
+
+	var merged []any
+	if !full {
+		merged = append(merged, dst...)
+	}
+	for _, item := range src {
+		id, _ := listID(item)
+		object := item.(map[string]any)
+		if i := listIndex(dst, id); i >= 0 && len(object) > 1 {
+			existing := dst[i].(map[string]any)
+			mergeReport(existing, object, full)
+			object = existing
+		}
+		if i := listIndex(merged, id); i >= 0 {
+			merged[i] = object
+		} else {
+			merged = append(merged, object)
+		}
+	}
+	return merged, true
+}
+
+// listIndex returns the index of the element with the given key, or -1.
+func listIndex(list []any, id string) int {
+	for i, item := range list {
+		if itemID, ok := listID(item); ok && itemID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func listID(item any) (string, bool) {
+	object, ok := item.(map[string]any)
+	if !ok {
+		return "", false
+	}
+	if id, ok := object["id"].(string); ok {
+		return id, true
+	}
+	node, ok := object["node"].(string)
+	return node, ok
+}
+
+// reportState converts the merged report back into the typed model.
+func reportState(report map[string]any) BambuLabsX1C {
+	var data BambuLabsX1C
+	raw, err := json.Marshal(map[string]any{"print": report})
+	if err != nil {
+		return data
+	}
+	// A field with an unexpected type only leaves that field unset, the rest
+	// of the report is still decoded.
+	json.Unmarshal(raw, &data)
+	return data
+}
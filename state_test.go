@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// decodeJSON parses a report object the same way decodeReport does.
+func decodeJSON(t *testing.T, s string) map[string]any {
+	t.Helper()
+	var report map[string]any
+	decoder := json.NewDecoder(bytes.NewReader([]byte(s)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&report); err != nil {
+		t.Fatalf("decoding %s: %v", s, err)
+	}
+	return report
+}
+
+func TestMergeReport(t *testing.T) {
+	tests := []struct {
+		name   string
+		report string
+		update string
+		want   string
+	}{
+		{
+			name:   "partial update keeps old fields",
+			report: `{"nozzle_temper": 220, "bed_temper": 60, "ams": {"ams": [{"id": "0", "humidity": "4", "tray": [{"id": "0", "tray_type": "PLA", "remain": 80}]}]}}`,
+			update: `{"msg": 1, "nozzle_temper": 221, "ams": {"ams": [{"id": "0", "tray": [{"id": "0", "remain": 79}]}]}}`,
+			want:   `{"msg": 1, "nozzle_temper": 221, "bed_temper": 60, "ams": {"ams": [{"id": "0", "humidity": "4", "tray": [{"id": "0", "tray_type": "PLA", "remain": 79}]}]}}`,
+		},
+		{
+			name:   "emptied slot replaces the old tray",
+			report: `{"ams": {"ams": [{"id": "0", "tray": [{"id": "0", "tray_type": "PLA"}, {"id": "2", "tray_type": "PETG", "tray_color": "FF0000FF", "remain": 50, "tag_uid": "1234"}]}]}}`,
+			update: `{"msg": 1, "ams": {"ams": [{"id": "0", "tray": [{"id": "2"}]}]}}`,
+			want:   `{"msg": 1, "ams": {"ams": [{"id": "0", "tray": [{"id": "0", "tray_type": "PLA"}, {"id": "2"}]}]}}`,
+		},
+		{
+			name:   "full report drops an unplugged AMS",
+			report: `{"ams": {"ams": [{"id": "0", "tray": [{"id": "0", "tray_type": "PLA"}]}, {"id": "1", "tray": [{"id": "0", "tray_type": "ABS"}]}]}}`,
+			update: `{"msg": 0, "ams": {"ams": [{"id": "1", "tray": [{"id": "0", "tray_type": "ABS"}]}]}}`,
+			want:   `{"msg": 0, "ams": {"ams": [{"id": "1", "tray": [{"id": "0", "tray_type": "ABS"}]}]}}`,
+		},
+		{
+			name:   "full report drops removed trays",
+			report: `{"ams": {"ams": [{"id": "0", "humidity": "4", "tray": [{"id": "0", "tray_type": "PLA"}, {"id": "1", "tray_type": "PETG"}]}]}}`,
+			update: `{"msg": 0, "ams": {"ams": [{"id": "0", "tray": [{"id": "1", "remain": 20}]}]}}`,
+			want:   `{"msg": 0, "ams": {"ams": [{"id": "0", "humidity": "4", "tray": [{"id": "1", "tray_type": "PETG", "remain": 20}]}]}}`,
+		},
+		{
+			name:   "lists without keys are replaced",
+			report: `{"hms": [{"attr": 50331904, "code": 131073}, {"attr": 117440768, "code": 196609}]}`,
+			update: `{"msg": 1, "hms": [{"attr": 117440768, "code": 196609}]}`,
+			want:   `{"msg": 1, "hms": [{"attr": 117440768, "code": 196609}]}`,
+		},
+		{
+			name:   "empty list clears the old one",
+			report: `{"hms": [{"attr": 50331904, "code": 131073}]}`,
+			update: `{"msg": 1, "hms": []}`,
+			want:   `{"msg": 1, "hms": []}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report := decodeJSON(t, test.report)
+			update := decodeJSON(t, test.update)
+			mergeReport(report, update, isFullReport(update))
+
+			if want := decodeJSON(t, test.want); !reflect.DeepEqual(report, want) {
+				got, _ := json.Marshal(report)
+				t.Errorf("merged report is %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestIsFullReport(t *testing.T) {
+	tests := []struct {
+		update string
+		want   bool
+	}{
+		{`{"msg": 0}`, true},
+		{`{"msg": 1}`, false},
+		{`{"nozzle_temper": 220}`, false},
+	}
+
+	for _, test := range tests {
+		if got := isFullReport(decodeJSON(t, test.update)); got != test.want {
+			t.Errorf("isFullReport(%s) = %v, want %v", test.update, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// newTLSConfig builds the TLS settings for one printer. By default the
+// printer certificate is not checked. With tls_verify set it has to chain
+// to the CA in ca_file and carry the printer serial as its CN, and with
+// fingerprint set it has to match the pinned SHA-256.
+func newTLSConfig(config printerConfig) (*tls.Config, error) {
+	if !config.TLSVerify && config.Fingerprint == "" {
+		return &tls.Config{InsecureSkipVerify: true}, nil
+	}
+
+	var roots *x509.CertPool
+	if config.TLSVerify {
+		ca, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file: %w", err)
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in CA file")
+		}
+	}
+
+	fingerprint := normalizeFingerprint(config.Fingerprint)
+	serial := config.Serial
+
+	return &tls.Config{
+		// Printer certificates name the serial in the CN and have no SAN, which
+		// the standard hostname check rejects, so verification is done in
+		// VerifyConnection instead.
+		InsecureSkipVerify: true,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return fmt.Errorf("printer sent no certificate")
+			}
+			leaf := state.PeerCertificates[0]
+
+			if roots != nil {
+				intermediates := x509.NewCertPool()
+				for _, cert := range state.PeerCertificates[1:] {
+					intermediates.AddCert(cert)
+				}
+				opts := x509.VerifyOptions{Roots: roots, Intermediates: intermediates}
+				if _, err := leaf.Verify(opts); err != nil {
+					return fmt.Errorf("verifying printer certificate: %w", err)
+				}
+				if leaf.Subject.CommonName != serial {
+					return fmt.Errorf("printer certificate is for %q, expected %q", leaf.Subject.CommonName, serial)
+				}
+			}
+
+			if fingerprint != "" {
+				sum := sha256.Sum256(leaf.Raw)
+				if hex.EncodeToString(sum[:]) != fingerprint {
+					return fmt.Errorf("printer certificate fingerprint does not match")
+				}
+			}
+			return nil
+		},
+	}, nil
+}
+
+// normalizeFingerprint accepts fingerprints in the AA:BB:.. form openssl
+// prints as well as plain hex.
+func normalizeFingerprint(fingerprint string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(fingerprint), ":", ""))
+}
@@ -0,0 +1,112 @@
+// Command hmsgen rebuilds hms_en.json from the English HMS list Bambu Lab
+// publishes for its apps and wiki.
+//
+//	go run ./tools/hmsgen -o hms_en.json
+//
+// Codes already in the output file are kept unless the download has newer
+// text for them, so local additions survive a refresh.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultURL = "https://e.bambulab.com/query.php?lang=en"
+
+// hmsList is the part of the response that holds the HMS texts. ecode is the
+// attr and code of an HMS entry as 16 hex digits.
+type hmsList struct {
+	Data struct {
+		DeviceHMS struct {
+			En []struct {
+				Ecode string `json:"ecode"`
+				Intro string `json:"intro"`
+			} `json:"en"`
+		} `json:"device_hms"`
+	} `json:"data"`
+}
+
+func main() {
+	url := flag.String("url", defaultURL, "URL of the published HMS list")
+	output := flag.String("o", "hms_en.json", "file to write")
+	flag.Parse()
+
+	descriptions := map[string]string{}
+	if raw, err := os.ReadFile(*output); err == nil {
+		if err := json.Unmarshal(raw, &descriptions); err != nil {
+			log.Fatalf("parsing %s: %v", *output, err)
+		}
+	}
+
+	list, err := download(*url)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	added := 0
+	for _, entry := range list.Data.DeviceHMS.En {
+		id, ok := shortID(entry.Ecode)
+		if !ok || strings.TrimSpace(entry.Intro) == "" {
+			continue
+		}
+		descriptions[id] = strings.TrimSpace(entry.Intro)
+		added++
+	}
+	if added == 0 {
+		log.Fatalf("no HMS codes found at %s", *url)
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		log.Fatal(err)
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(descriptions); err != nil {
+		log.Fatalf("writing %s: %v", *output, err)
+	}
+	if err := file.Close(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("wrote %d codes to %s\n", len(descriptions), *output)
+}
+
+func download(url string) (hmsList, error) {
+	var list hmsList
+	client := &http.Client{Timeout: time.Minute}
+	resp, err := client.Get(url)
+	if err != nil {
+		return list, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return list, fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return list, fmt.Errorf("parsing %s: %w", url, err)
+	}
+	return list, nil
+}
+
+// shortID turns an ecode such as 0300010000010001 into the
+// 0300_0100_0001_0001 form hms_en.json is keyed by.
+func shortID(ecode string) (string, bool) {
+	ecode = strings.ToUpper(strings.TrimSpace(ecode))
+	if len(ecode) != 16 {
+		return "", false
+	}
+	for _, c := range ecode {
+		if !strings.ContainsRune("0123456789ABCDEF", c) {
+			return "", false
+		}
+	}
+	return ecode[0:4] + "_" + ecode[4:8] + "_" + ecode[8:12] + "_" + ecode[12:16], true
+}
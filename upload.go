@@ -0,0 +1,24 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// uploadStates are the usual values of upload.status. Any other value the
+// printer sends is still exported by collectStateSet.
+var uploadStates = []string{"idle", "uploading", "upload_success", "upload_failed"}
+
+// collectUpload exports the transfer of a job file to the printer.
+func (collector *bambulabsCollector) collectUpload(ch chan<- prometheus.Metric, labels []string, report BambuLabsX1C) {
+	upload := report.Print.Upload
+	if upload.Status == "" {
+		return
+	}
+
+	collectStateSet(ch, collector.uploadStatusMetric, labels, uploadStates, upload.Status)
+	ch <- prometheus.MustNewConstMetric(collector.uploadProgressMetric, prometheus.GaugeValue, float64(upload.Progress), labels...)
+	ch <- prometheus.MustNewConstMetric(collector.uploadFileSizeMetric, prometheus.GaugeValue, float64(upload.FileSize), labels...)
+	ch <- prometheus.MustNewConstMetric(collector.uploadBytesDoneMetric, prometheus.GaugeValue, float64(upload.FinishSize), labels...)
+	ch <- prometheus.MustNewConstMetric(collector.uploadSpeedMetric, prometheus.GaugeValue, float64(upload.Speed), labels...)
+	ch <- prometheus.MustNewConstMetric(collector.uploadTimeRemainingMetric, prometheus.GaugeValue, float64(upload.TimeRemaining), labels...)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collectXcam exports which of the camera based failure detectors are
+// turned on. Printers that don't send the xcam block get no series.
+func (collector *bambulabsCollector) collectXcam(ch chan<- prometheus.Metric, labels []string, report BambuLabsX1C) {
+	xcam := report.Print.Xcam
+	if xcam == nil {
+		return
+	}
+
+	detectors := []struct {
+		name    string
+		enabled bool
+	}{
+		{"spaghetti_detector", xcam.SpaghettiDetector},
+		{"first_layer_inspector", xcam.FirstLayerInspector},
+		{"buildplate_marker_detector", xcam.BuildplateMarkerDetector},
+		{"printing_monitor", xcam.PrintingMonitor},
+		{"print_halt", xcam.PrintHalt},
+		{"allow_skip_parts", xcam.AllowSkipParts},
+	}
+	for _, detector := range detectors {
+		enabled := 0.0
+		if detector.enabled {
+			enabled = 1
+		}
+		ch <- prometheus.MustNewConstMetric(collector.xcamDetectorMetric, prometheus.GaugeValue, enabled, append(labels, detector.name)...)
+	}
+
+	if xcam.HaltPrintSensitivity != "" {
+		ch <- prometheus.MustNewConstMetric(collector.xcamSensitivityMetric, prometheus.GaugeValue, 1, append(labels, xcam.HaltPrintSensitivity)...)
+	}
+}